@@ -1,22 +1,18 @@
-// This file is for local development.
-// For Cloud Functions, the function.go file is used instead.
+// This file is for local development and self-hosting.
+// The Cloud Function (function.go) builds the same handler with app.NewHandler.
 
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
+	"example.com/webhook-receiver/internal/app"
 	"example.com/webhook-receiver/internal/config"
-	"example.com/webhook-receiver/internal/domain"
 	"example.com/webhook-receiver/internal/handlers"
-	"example.com/webhook-receiver/internal/repositories"
 	"example.com/webhook-receiver/internal/services"
-
-	"context"
-
-	firebase "firebase.google.com/go/v4"
 )
 
 func main() {
@@ -26,36 +22,36 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Initialize Firebase
-	ctx := context.Background()
-	firebaseApp, err := firebase.NewApp(ctx, &firebase.Config{
-		DatabaseURL: cfg.FirebaseDatabaseURL,
-	})
-	if err != nil {
-		log.Fatalf("Failed to initialize Firebase: %v", err)
-	}
-
-	dbClient, err := firebaseApp.Database(ctx)
+	// Build the webhook handlers against the configured backends
+	logger := services.NewSimpleLogger()
+	handler, err := app.NewHandler(context.Background(), cfg, logger)
 	if err != nil {
-		log.Fatalf("Failed to get Firebase database client: %v", err)
+		log.Fatalf("Failed to initialize: %v", err)
 	}
 
-	// Create dependencies
-	logger := services.NewSimpleLogger()
-	validator := domain.NewHMACValidator(cfg.WebhookSecret)
-	writer := repositories.NewFirebaseRepository(dbClient)
-
-	// Compose service
-	webhookService := services.NewWebhookService(validator, writer, logger)
-
-	// Create handler
-	handler := handlers.NewWebhookHandler(webhookService, logger)
-
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Port)
-	logger.Info("Starting webhook server", "addr", addr)
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	if cfg.TLSClientCAFile != "" {
+		tlsConfig, err := handlers.NewMutualTLSConfig(cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to configure mutual TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+		server.Handler = handlers.NewClientCertMiddleware(server.Handler, cfg.TLSAllowedClients, logger)
+	}
+
+	if cfg.TLSCertFile == "" {
+		logger.Info("Starting webhook server", "addr", addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
+	logger.Info("Starting webhook server", "addr", addr, "tls", true, "mtls", cfg.TLSClientCAFile != "")
+	if err := server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
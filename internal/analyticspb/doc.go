@@ -0,0 +1,5 @@
+// Package analyticspb holds the protobuf wire format for analytics events
+// analytics.pb.go is generated from analytics.proto; regenerate after editing it
+package analyticspb
+
+//go:generate protoc --proto_path=../.. --go_out=../.. --go_opt=paths=source_relative internal/analyticspb/analytics.proto
@@ -0,0 +1,278 @@
+// Package app is the composition root shared by the Cloud Function and the
+// local server: it builds every dependency from config.Config
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/db"
+
+	"example.com/webhook-receiver/internal/config"
+	"example.com/webhook-receiver/internal/domain"
+	"example.com/webhook-receiver/internal/handlers"
+	"example.com/webhook-receiver/internal/repositories"
+	"example.com/webhook-receiver/internal/services"
+)
+
+// NewHandler connects to the configured backends and returns the handler for
+// every webhook, ingest and tenant endpoint, behind the IP allowlist
+// Transport concerns (TLS, client certificates) are left to the caller
+func NewHandler(ctx context.Context, cfg *config.Config, logger domain.Logger) (http.Handler, error) {
+	firebaseApp, err := firebase.NewApp(ctx, &firebase.Config{
+		ProjectID:   cfg.FirebaseProjectID,
+		DatabaseURL: cfg.FirebaseDatabaseURL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Firebase: %w", err)
+	}
+
+	// Connect only to the backends the config uses
+	var firestoreClient *firestore.Client
+	if cfg.UsesFirestore() {
+		if firestoreClient, err = firebaseApp.Firestore(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get Firestore client: %w", err)
+		}
+	}
+	var dbClient *db.Client
+	if cfg.UsesRTDB() {
+		if dbClient, err = firebaseApp.Database(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get Firebase database client: %w", err)
+		}
+	}
+
+	writer, err := newWriter(ctx, cfg, firestoreClient, dbClient, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EncryptionKeys != nil {
+		writer = services.NewEncryptingWriter(writer, domain.NewFieldCipher(cfg.EncryptionKeys))
+	}
+	nonces := newNonceStore(cfg, firestoreClient)
+
+	redactor, err := newRedactor(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure redaction: %w", err)
+	}
+
+	// Compose one service and handler per endpoint, each with its own scheme
+	registry := domain.NewDefaultSchemeRegistry()
+	options := schemeOptions(cfg, logger)
+	mux := http.NewServeMux()
+	for _, endpoint := range cfg.Endpoints {
+		handler, err := newEndpointHandler(registry, endpoint.Scheme, endpoint.Keys, options, cfg.Enrichers, cfg, redactor, nonces, writer, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure endpoint %s: %w", endpoint.Path, err)
+		}
+		mux.Handle(endpoint.Path, handler)
+		logger.Info("Registered webhook endpoint", "path", endpoint.Path, "scheme", endpoint.Scheme)
+	}
+
+	// Bulk NDJSON loads stream into the same writer and nonce store
+	if cfg.IngestPath != "" {
+		handler, err := newIngestHandler(registry, options, cfg, redactor, nonces, writer, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure ingest endpoint %s: %w", cfg.IngestPath, err)
+		}
+		mux.Handle(cfg.IngestPath, handler)
+		logger.Info("Registered ingest endpoint", "path", cfg.IngestPath, "scheme", cfg.IngestScheme)
+	}
+
+	// Tenants get their own credentials and rate limit behind one router,
+	// addressed by /tenants/{tenant} or the X-Webhook-Sender header
+	if len(cfg.Tenants) > 0 {
+		routes := make([]handlers.TenantRoute, 0, len(cfg.Tenants))
+		for i := range cfg.Tenants {
+			tenant := &cfg.Tenants[i]
+			enrichers := cfg.Enrichers
+			if tenant.Enrichers != nil {
+				enrichers = tenant.Enrichers
+			}
+			// A tenant verifies against its own public keys and JWKS only
+			tenantOptions := options
+			tenantOptions.PublicKeys = tenant.PublicKeys
+			tenantOptions.JWKS = tenant.JWKS
+			tenantOptions.JWTPolicy = tenant.JWTPolicy
+			handler, err := newEndpointHandler(registry, tenant.Scheme, tenant.Keys, tenantOptions, enrichers, cfg, redactor, nonces, writer, logger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure tenant %s: %w", tenant.ID, err)
+			}
+			if tenant.RateLimit > 0 {
+				handler = handlers.NewRateLimitMiddleware(handler, tenant.RateLimit, tenant.Burst, logger)
+			}
+			routes = append(routes, handlers.TenantRoute{Tenant: tenant, Handler: handler})
+			logger.Info("Registered webhook tenant", "tenant", tenant.ID, "scheme", tenant.Scheme)
+		}
+
+		router := handlers.NewTenantRouter(routes, logger)
+		mux.Handle("/tenants/{"+handlers.TenantPathValue+"}", router)
+		mux.Handle("/tenants", router)
+	}
+
+	logger.Info("Webhook handler initialized", "environment", cfg.Environment, "storage", cfg.StorageBackend, "sinks", strings.Join(cfg.Sinks(), ","), "nonceStore", cfg.NonceStore)
+
+	// Source IP allowlist wraps everything so it runs before any body is read
+	if len(cfg.IPAllowlist) > 0 {
+		return handlers.NewIPAllowlistMiddleware(mux, cfg.IPAllowlist, cfg.TrustedProxyHops, cfg.ForwardedHeader, logger), nil
+	}
+	return mux, nil
+}
+
+// newWriter builds the AnalyticsWriter for the configured sinks (see
+// config.Config.Sinks). A single sink is used as it is; several are written
+// concurrently, and a sink named in BEST_EFFORT_SINKS is retried in the
+// background when it fails, unless cfg.SinkRetries is off
+func newWriter(ctx context.Context, cfg *config.Config, firestoreClient *firestore.Client, dbClient *db.Client, logger domain.Logger) (domain.AnalyticsWriter, error) {
+	var sinks []services.Sink
+	for _, name := range cfg.Sinks() {
+		writer, err := newSink(ctx, cfg, name, firestoreClient, dbClient)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s sink: %w", name, err)
+		}
+		sinks = append(sinks, services.Sink{Name: name, Writer: writer, Required: cfg.IsRequiredSink(name)})
+	}
+	if len(sinks) == 1 {
+		return sinks[0].Writer, nil
+	}
+
+	var retries domain.RetryQueue
+	if cfg.SinkRetries {
+		retries = services.NewMemoryRetryQueue(services.DefaultRetryCapacity, services.DefaultRetryMaxAttempts, services.DefaultRetryBackoff, logger)
+	}
+	return services.NewFanOutWriter(sinks, retries, logger), nil
+}
+
+// newSink builds the writer for one sink name
+// The SQL database is pinged so a bad DSN fails at startup, not on the first write
+func newSink(ctx context.Context, cfg *config.Config, name string, firestoreClient *firestore.Client, dbClient *db.Client) (domain.AnalyticsWriter, error) {
+	switch name {
+	case config.StorageRTDB:
+		return repositories.NewFirebaseRepository(dbClient), nil
+	case config.SinkArchive:
+		file, err := os.OpenFile(cfg.ArchiveFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return repositories.NewArchiveRepository(file), nil
+	case config.SinkSQL:
+		sqlDB, err := sql.Open(cfg.SQLDriver, cfg.SQLDSN)
+		if err != nil {
+			return nil, err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		return repositories.NewSQLRepository(sqlDB, cfg.SQLDriver, cfg.SQLTable), nil
+	default:
+		return repositories.NewFirestoreRepository(firestoreClient), nil
+	}
+}
+
+// newEndpointHandler builds the validator for a scheme and its keys and
+// wraps it in a webhook service and HTTP handler that enriches with enricher
+func newEndpointHandler(
+	registry *domain.SchemeRegistry,
+	schemeName string,
+	keys []domain.SigningKey,
+	options domain.SchemeOptions,
+	enricher domain.Enricher,
+	cfg *config.Config,
+	redactor domain.RecordRedactor,
+	nonces domain.NonceStore,
+	writer domain.AnalyticsWriter,
+	logger domain.Logger,
+) (http.Handler, error) {
+	scheme, validator, err := newValidator(registry, schemeName, keys, options)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := domain.NewContentDecoder(int64(cfg.MaxDecompressedBytes))
+	events := services.NewDefaultEventRouter(writer, newRecordValidator(cfg, logger), enricher, nonces, logger, services.DeleteMode(cfg.DeleteMode))
+	webhookService := services.NewWebhookService(validator, decoder, domain.NewDefaultPayloadFormats(domain.DecodeMode(cfg.DecodeMode)), redactor, nonces, events, logger)
+	return handlers.NewWebhookHandler(webhookService, logger, scheme.SignatureHeader), nil
+}
+
+// newIngestHandler builds the NDJSON bulk-load endpoint
+// The body is streamed, so only schemes that authenticate from headers are allowed
+func newIngestHandler(
+	registry *domain.SchemeRegistry,
+	options domain.SchemeOptions,
+	cfg *config.Config,
+	redactor domain.RecordRedactor,
+	nonces domain.NonceStore,
+	writer domain.AnalyticsWriter,
+	logger domain.Logger,
+) (http.Handler, error) {
+	scheme, validator, err := newValidator(registry, cfg.IngestScheme, cfg.IngestKeys, options)
+	if err != nil {
+		return nil, err
+	}
+	if scheme.SignsBody {
+		return nil, fmt.Errorf("scheme %q signs the body, which the streaming endpoint cannot verify before writing; use %q", scheme.Name, domain.SchemeJWT)
+	}
+
+	decoder := domain.NewContentDecoder(int64(cfg.MaxDecompressedBytes))
+	ingestService := services.NewIngestService(validator, decoder, redactor, newRecordValidator(cfg, logger), cfg.Enrichers, nonces, writer, logger, cfg.IngestChunkSize, domain.DecodeMode(cfg.DecodeMode))
+	return handlers.NewIngestHandler(ingestService, logger), nil
+}
+
+// schemeOptions collects the deployment-wide scheme settings
+func schemeOptions(cfg *config.Config, logger domain.Logger) domain.SchemeOptions {
+	return domain.SchemeOptions{
+		Tolerance:   cfg.SignatureTolerance,
+		AllowLegacy: cfg.AllowLegacySignatures,
+		PublicKeys:  cfg.PublicKeys,
+		JWKS:        cfg.JWKS,
+		JWTPolicy:   cfg.JWTPolicy,
+		Logger:      logger,
+	}
+}
+
+// newValidator looks up a scheme and builds its validator from options
+func newValidator(
+	registry *domain.SchemeRegistry,
+	schemeName string,
+	keys []domain.SigningKey,
+	options domain.SchemeOptions,
+) (domain.SignatureScheme, domain.SignatureValidator, error) {
+	scheme, err := registry.Get(schemeName)
+	if err != nil {
+		return scheme, nil, err
+	}
+
+	validator, err := scheme.NewValidator(keys, options)
+	return scheme, validator, err
+}
+
+// newRecordValidator checks records against the JSON Schema, then applies the
+// semantic rules, which may correct them, then checks the schema again so a
+// correction cannot store a record the schema rejects
+func newRecordValidator(cfg *config.Config, logger domain.Logger) domain.RecordValidator {
+	return domain.RecordValidators{cfg.RecordSchema, domain.NewRuleSet(cfg.RecordRules, logger), cfg.RecordSchema}
+}
+
+// newNonceStore builds the configured duplicate-delivery store
+func newNonceStore(cfg *config.Config, firestoreClient *firestore.Client) domain.NonceStore {
+	if cfg.NonceStore == config.NonceStoreFirestore {
+		return repositories.NewFirestoreNonceStore(firestoreClient, cfg.NonceTTL)
+	}
+	return repositories.NewMemoryNonceStore(cfg.NonceTTL)
+}
+
+// newRedactor masks or hashes emails, phone and card numbers and the
+// REDACT_DENY_LIST terms
+func newRedactor(cfg *config.Config, logger domain.Logger) (*domain.Redactor, error) {
+	detectors := domain.DefaultDetectors()
+	if denyList := domain.NewDenyListDetector(cfg.RedactDenyList); denyList != nil {
+		detectors = append(detectors, denyList)
+	}
+	return domain.NewRedactor(detectors, domain.RedactMode(cfg.RedactMode), cfg.RedactHashKey, logger)
+}
@@ -1,39 +1,850 @@
 package config
 
 import (
+	"crypto"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// EndpointConfig binds an HTTP path to one signature scheme and its keys
+type EndpointConfig struct {
+	Path   string
+	Scheme string
+	Keys   []domain.SigningKey
+}
+
+// Nonce stores selectable via NONCE_STORE
+const (
+	NonceStoreMemory    = "memory"
+	NonceStoreFirestore = "firestore"
+)
+
+// Forwarding headers selectable via FORWARDED_HEADER
+const (
+	ForwardedHeaderXFF     = "X-Forwarded-For"
+	ForwardedHeaderRFC7239 = "Forwarded"
 )
 
+// Storage backends selectable via STORAGE_BACKEND
+const (
+	StorageFirestore = "firestore"
+	StorageRTDB      = "rtdb"
+	StorageBoth      = "both"
+)
+
+// Sinks written alongside the storage backend when configured
+const (
+	SinkArchive = "archive" // ARCHIVE_FILE
+	SinkSQL     = "sql"     // SQL_DRIVER and SQL_DSN
+)
+
+// DefaultSQLTable is the reporting table when SQL_TABLE is not set
+const DefaultSQLTable = "analytics_records"
+
+// sqlIdentifier is a table name safe to put in a statement unquoted
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// collectionPrefix keeps a tenant's collection a single Firestore collection
+// ID and a single Realtime Database key: no "/" (which would add path
+// segments), and none of the characters RTDB keys forbid
+var collectionPrefix = regexp.MustCompile(`^[A-Za-z0-9_-]{0,100}$`)
+
 // Config holds application configuration
 type Config struct {
-	WebhookSecret       string
+	// WebhookKeys are the signing secrets currently accepted from senders
+	// Built from WEBHOOK_SECRETS, or from WEBHOOK_SECRET as a single "default" key
+	WebhookKeys []domain.SigningKey
+
 	FirebaseProjectID   string
 	FirebaseDatabaseURL string
 	Port                string
 	Environment         string
+
+	// StorageBackend selects where records are written: Firestore, the Realtime
+	// Database, or both while migrating between them
+	StorageBackend string
+
+	// BestEffortSinks names the sinks whose failures are logged and retried
+	// in the background instead of failing the delivery (see Sinks)
+	BestEffortSinks []string
+
+	// SinkRetries runs the in-memory queue that retries best-effort sinks in
+	// the background. With it off, their failures are only logged
+	SinkRetries bool
+
+	// ArchiveFile, when set, appends every write to this JSON Lines file
+	ArchiveFile string
+
+	// SQLDriver and SQLDSN, when set, also write records to SQLTable through
+	// database/sql; the driver must be linked into the binary
+	SQLDriver string
+	SQLDSN    string
+	SQLTable  string
+
+	// Endpoints lists the webhook paths and the signature scheme each accepts
+	// Built from WEBHOOK_ENDPOINTS, or a single "/" endpoint using SIGNATURE_SCHEME
+	Endpoints []EndpointConfig
+
+	// SignatureTolerance is the maximum allowed skew between the sender
+	// timestamp and the receiver clock (replay window)
+	SignatureTolerance time.Duration
+
+	// PublicKeys is the Ed25519 keyring loaded from WEBHOOK_PUBLIC_KEYS
+	// (comma-separated PEM or JWK/JWKS files)
+	PublicKeys domain.Ed25519Keyring
+
+	// NonceStore selects where delivered message IDs are remembered
+	NonceStore string
+
+	// NonceTTL is how long a message ID is remembered; it should be at
+	// least SignatureTolerance so replays inside the window are caught
+	NonceTTL time.Duration
+
+	// JWKS and JWTPolicy configure the jwt scheme; JWKS is read from
+	// JWT_JWKS, a file path or a local http(s) URL
+	JWKS      map[string]crypto.PublicKey
+	JWTPolicy domain.JWTPolicy
+
+	// IPAllowlist restricts senders to these CIDRs (empty allows all)
+	// TrustedProxyHops is how many reverse proxies sit in front of the server
+	IPAllowlist      []netip.Prefix
+	TrustedProxyHops int
+
+	// ForwardedHeader is the header the trusted proxies append the client to
+	// Only this header is read, so a sender cannot supply a different one
+	ForwardedHeader string
+
+	// TLS settings for the local server; TLSClientCAFile enables mutual TLS
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCAFile   string
+	TLSAllowedClients []string
+
+	// MaxDecompressedBytes caps the size a compressed request body may expand to
+	MaxDecompressedBytes int
+
+	// Ingest configures the NDJSON bulk-load endpoint (disabled when IngestPath
+	// is empty). IngestScheme must authenticate from headers alone, e.g. jwt
+	IngestPath      string
+	IngestScheme    string
+	IngestKeys      []domain.SigningKey
+	IngestChunkSize int
+
+	// RecordSchema validates every record before it is stored; compiled from
+	// RECORD_SCHEMA_FILE, or domain.DefaultRecordSchema when unset
+	RecordSchema domain.RecordValidator
+
+	// RecordRules are the semantic rules (ranges, enums, cross-field checks)
+	// from RULES_FILE, or domain.DefaultRules when unset
+	RecordRules []domain.Rule
+
+	// RedactMode is how personal data found in query and reasoning is replaced:
+	// "mask" or "hash" (keyed with RedactHashKey from REDACT_HASH_KEY)
+	RedactMode    string
+	RedactHashKey []byte
+
+	// RedactDenyList are extra terms (e.g. staff names) redacted as whole
+	// words, from REDACT_DENY_LIST
+	RedactDenyList []string
+
+	// EncryptionKeys encrypt query and reasoning at rest when ENCRYPTION_KEY_FILE
+	// is set; nil stores them in plaintext
+	EncryptionKeys domain.KeyProvider
+
+	// Enrichers derive fields from each validated record before it is written,
+	// named in ENRICHERS ("none" for no enrichment); tenants may override them
+	Enrichers domain.Enrichers
+
+	// DeleteMode selects how analytics_record_deleted events are applied:
+	// "tombstone" marks the record deleted, "delete" removes it
+	DeleteMode string
+
+	// DecodeMode selects how JSON bodies are decoded: "permissive" keeps unknown
+	// record fields in AnalyticsRecord.Extra, "strict" rejects them along with
+	// duplicate keys and trailing data
+	DecodeMode string
+
+	// Tenants are the registered senders loaded from TENANTS_FILE, each with
+	// its own credentials, rate limit and collection prefix
+	Tenants []domain.Tenant
+
+	// AllowLegacySignatures accepts body-only "sha256=<hex>" signatures
+	// while senders migrate to timestamped signatures
+	AllowLegacySignatures bool
 }
 
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
-		WebhookSecret:       os.Getenv("WEBHOOK_SECRET"),
-		FirebaseProjectID:   os.Getenv("FIREBASE_PROJECT_ID"),
+		FirebaseProjectID:   getEnvOrDefault("FIREBASE_PROJECT_ID", os.Getenv("GOOGLE_CLOUD_PROJECT")),
 		FirebaseDatabaseURL: os.Getenv("FIREBASE_DATABASE_URL"),
 		Port:                getEnvOrDefault("PORT", "8080"),
 		Environment:         getEnvOrDefault("ENVIRONMENT", "development"),
+		NonceStore:          getEnvOrDefault("NONCE_STORE", NonceStoreMemory),
+		StorageBackend:      getEnvOrDefault("STORAGE_BACKEND", StorageFirestore),
+		ForwardedHeader:     http.CanonicalHeaderKey(getEnvOrDefault("FORWARDED_HEADER", ForwardedHeaderXFF)),
+		BestEffortSinks:     getEnvList("BEST_EFFORT_SINKS"),
+		ArchiveFile:         os.Getenv("ARCHIVE_FILE"),
+		SQLDriver:           os.Getenv("SQL_DRIVER"),
+		SQLDSN:              os.Getenv("SQL_DSN"),
+		SQLTable:            getEnvOrDefault("SQL_TABLE", DefaultSQLTable),
+		DeleteMode:          getEnvOrDefault("DELETE_MODE", "tombstone"),
+		DecodeMode:          getEnvOrDefault("DECODE_MODE", string(domain.DecodePermissive)),
+		RedactMode:          getEnvOrDefault("REDACT_MODE", string(domain.RedactMask)),
+		RedactHashKey:       []byte(os.Getenv("REDACT_HASH_KEY")),
+		RedactDenyList:      getEnvList("REDACT_DENY_LIST"),
+		IngestPath:          os.Getenv("INGEST_PATH"),
+		IngestScheme:        getEnvOrDefault("INGEST_SCHEME", domain.SchemeJWT),
+		TLSCertFile:         os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:          os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile:     os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSAllowedClients:   getEnvList("TLS_ALLOWED_CLIENTS"),
+	}
+
+	var err error
+	if cfg.WebhookKeys, err = loadSigningKeys(); err != nil {
+		return nil, err
+	}
+	if cfg.PublicKeys, err = loadPublicKeys(); err != nil {
+		return nil, err
+	}
+	if cfg.JWKS, err = loadJWKS("JWT_JWKS", os.Getenv("JWT_JWKS")); err != nil {
+		return nil, err
+	}
+	if cfg.JWTPolicy, err = loadJWTPolicy(); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoints, err = loadEndpoints(cfg.WebhookKeys); err != nil {
+		return nil, err
+	}
+	if cfg.SignatureTolerance, err = getEnvDuration("SIGNATURE_TOLERANCE", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.NonceTTL, err = getEnvDuration("NONCE_TTL", 2*cfg.SignatureTolerance); err != nil {
+		return nil, err
+	}
+	if cfg.MaxDecompressedBytes, err = getEnvInt("MAX_DECOMPRESSED_BYTES", domain.DefaultMaxDecodedBytes); err != nil {
+		return nil, err
+	}
+	if cfg.IngestKeys, err = loadSchemeKeys(cfg.IngestScheme, cfg.WebhookKeys); err != nil {
+		return nil, err
+	}
+	if cfg.IngestChunkSize, err = getEnvInt("INGEST_CHUNK_SIZE", domain.MaxBatchRecords); err != nil {
+		return nil, err
+	}
+	if cfg.RecordSchema, err = loadRecordSchema(os.Getenv("RECORD_SCHEMA_FILE")); err != nil {
+		return nil, err
+	}
+	if cfg.RecordRules, err = loadRecordRules(os.Getenv("RULES_FILE")); err != nil {
+		return nil, err
+	}
+	if cfg.EncryptionKeys, err = loadEncryptionKeys(os.Getenv("ENCRYPTION_KEY_FILE")); err != nil {
+		return nil, err
+	}
+	if cfg.Enrichers, err = loadEnrichers(); err != nil {
+		return nil, err
+	}
+	if cfg.Tenants, err = loadTenants(os.Getenv("TENANTS_FILE"), cfg.JWTPolicy); err != nil {
+		return nil, err
+	}
+	if cfg.IPAllowlist, err = loadIPAllowlist(); err != nil {
+		return nil, err
+	}
+	if cfg.TrustedProxyHops, err = getEnvInt("TRUSTED_PROXY_HOPS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.AllowLegacySignatures, err = getEnvBool("ALLOW_LEGACY_SIGNATURES", false); err != nil {
+		return nil, err
+	}
+	if cfg.SinkRetries, err = getEnvBool("SINK_RETRIES", true); err != nil {
+		return nil, err
 	}
 
 	// Validate required fields
-	if cfg.WebhookSecret == "" {
-		return nil, fmt.Errorf("WEBHOOK_SECRET environment variable is required")
+	if cfg.SignatureTolerance <= 0 {
+		return nil, fmt.Errorf("SIGNATURE_TOLERANCE must be positive, got %s", cfg.SignatureTolerance)
+	}
+	if cfg.NonceTTL < cfg.SignatureTolerance {
+		return nil, fmt.Errorf("NONCE_TTL (%s) must be at least SIGNATURE_TOLERANCE (%s)", cfg.NonceTTL, cfg.SignatureTolerance)
+	}
+	if cfg.NonceStore != NonceStoreMemory && cfg.NonceStore != NonceStoreFirestore {
+		return nil, fmt.Errorf("NONCE_STORE must be %q or %q, got %q", NonceStoreMemory, NonceStoreFirestore, cfg.NonceStore)
+	}
+	if err := cfg.validateStorage(); err != nil {
+		return nil, err
+	}
+	if cfg.DeleteMode != "tombstone" && cfg.DeleteMode != "delete" {
+		return nil, fmt.Errorf("DELETE_MODE must be %q or %q, got %q", "tombstone", "delete", cfg.DeleteMode)
+	}
+	if cfg.DecodeMode != string(domain.DecodePermissive) && cfg.DecodeMode != string(domain.DecodeStrict) {
+		return nil, fmt.Errorf("DECODE_MODE must be %q or %q, got %q", domain.DecodePermissive, domain.DecodeStrict, cfg.DecodeMode)
+	}
+	if cfg.RedactMode != string(domain.RedactMask) && cfg.RedactMode != string(domain.RedactHash) {
+		return nil, fmt.Errorf("REDACT_MODE must be %q or %q, got %q", domain.RedactMask, domain.RedactHash, cfg.RedactMode)
+	}
+	if cfg.RedactMode == string(domain.RedactHash) && len(cfg.RedactHashKey) == 0 {
+		return nil, fmt.Errorf("REDACT_HASH_KEY is required when REDACT_MODE is %q", domain.RedactHash)
+	}
+	if cfg.MaxDecompressedBytes <= 0 {
+		return nil, fmt.Errorf("MAX_DECOMPRESSED_BYTES must be positive, got %d", cfg.MaxDecompressedBytes)
+	}
+	if cfg.IngestPath != "" && !strings.HasPrefix(cfg.IngestPath, "/") {
+		return nil, fmt.Errorf("INGEST_PATH must start with /, got %q", cfg.IngestPath)
+	}
+	if cfg.IngestChunkSize < 1 || cfg.IngestChunkSize > domain.MaxBatchRecords {
+		return nil, fmt.Errorf("INGEST_CHUNK_SIZE must be between 1 and %d, got %d", domain.MaxBatchRecords, cfg.IngestChunkSize)
+	}
+	if cfg.TrustedProxyHops < 0 {
+		return nil, fmt.Errorf("TRUSTED_PROXY_HOPS must not be negative, got %d", cfg.TrustedProxyHops)
+	}
+	if cfg.ForwardedHeader != ForwardedHeaderXFF && cfg.ForwardedHeader != ForwardedHeaderRFC7239 {
+		return nil, fmt.Errorf("FORWARDED_HEADER must be %q or %q, got %q", ForwardedHeaderXFF, ForwardedHeaderRFC7239, cfg.ForwardedHeader)
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if len(cfg.TLSAllowedClients) > 0 && cfg.TLSClientCAFile == "" {
+		return nil, fmt.Errorf("TLS_ALLOWED_CLIENTS requires TLS_CLIENT_CA_FILE")
 	}
-	// Note: Firebase Project ID is auto-detected from GCP environment
-	// FIREBASE_PROJECT_ID is optional and only needed for local testing
 
 	return cfg, nil
 }
 
+// UsesFirestore reports whether records or nonces are kept in Firestore
+func (c *Config) UsesFirestore() bool {
+	return c.StorageBackend != StorageRTDB || c.NonceStore == NonceStoreFirestore
+}
+
+// UsesRTDB reports whether records are written to the Realtime Database
+func (c *Config) UsesRTDB() bool {
+	return c.StorageBackend == StorageRTDB || c.StorageBackend == StorageBoth
+}
+
+// Sinks names everything a record is written to: the storage backends, then
+// the archive and the SQL store when they are configured
+func (c *Config) Sinks() []string {
+	var sinks []string
+	switch c.StorageBackend {
+	case StorageBoth:
+		sinks = append(sinks, StorageFirestore, StorageRTDB)
+	default:
+		sinks = append(sinks, c.StorageBackend)
+	}
+	if c.ArchiveFile != "" {
+		sinks = append(sinks, SinkArchive)
+	}
+	if c.SQLDriver != "" {
+		sinks = append(sinks, SinkSQL)
+	}
+	return sinks
+}
+
+// IsRequiredSink reports whether a failed write to sink fails the delivery
+func (c *Config) IsRequiredSink(sink string) bool {
+	for _, name := range c.BestEffortSinks {
+		if name == sink {
+			return false
+		}
+	}
+	return true
+}
+
+// validateStorage checks the settings each selected backend needs
+// On GCP (K_SERVICE is set) the Firestore project is detected automatically;
+// elsewhere FIREBASE_PROJECT_ID or GOOGLE_CLOUD_PROJECT must name it
+func (c *Config) validateStorage() error {
+	switch c.StorageBackend {
+	case StorageFirestore, StorageRTDB, StorageBoth:
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be %q, %q or %q, got %q", StorageFirestore, StorageRTDB, StorageBoth, c.StorageBackend)
+	}
+	if (c.SQLDriver == "") != (c.SQLDSN == "") {
+		return fmt.Errorf("SQL_DRIVER and SQL_DSN must be set together")
+	}
+	// Drivers register themselves when their package is linked in, so an
+	// unknown name here means the binary was built without it
+	if c.SQLDriver != "" && !slices.Contains(sql.Drivers(), c.SQLDriver) {
+		return fmt.Errorf("SQL_DRIVER %q is not linked into this binary (available: %s)", c.SQLDriver, strings.Join(sql.Drivers(), ", "))
+	}
+	if !sqlIdentifier.MatchString(c.SQLTable) {
+		return fmt.Errorf("SQL_TABLE must be a plain table name, got %q", c.SQLTable)
+	}
+
+	sinks := c.Sinks()
+	for _, name := range c.BestEffortSinks {
+		if len(sinks) < 2 {
+			return fmt.Errorf("BEST_EFFORT_SINKS needs more than one sink, got only %q", sinks[0])
+		}
+		if !slices.Contains(sinks, name) {
+			return fmt.Errorf("BEST_EFFORT_SINKS entries must be configured sinks (%s), got %q", strings.Join(sinks, ", "), name)
+		}
+	}
+	required := 0
+	for _, sink := range sinks {
+		if c.IsRequiredSink(sink) {
+			required++
+		}
+	}
+	if required == 0 {
+		return fmt.Errorf("BEST_EFFORT_SINKS must leave at least one sink required")
+	}
+	if c.UsesRTDB() && c.FirebaseDatabaseURL == "" {
+		return fmt.Errorf("FIREBASE_DATABASE_URL is required when STORAGE_BACKEND is %q", c.StorageBackend)
+	}
+	if c.UsesFirestore() && c.FirebaseProjectID == "" && os.Getenv("K_SERVICE") == "" {
+		return fmt.Errorf("FIREBASE_PROJECT_ID or GOOGLE_CLOUD_PROJECT is required for Firestore outside GCP")
+	}
+	return nil
+}
+
+// loadSigningKeys reads WEBHOOK_SECRETS, falling back to WEBHOOK_SECRET
+// as a single non-expiring key
+func loadSigningKeys() ([]domain.SigningKey, error) {
+	raw := os.Getenv("WEBHOOK_SECRETS")
+	if raw == "" {
+		if secret := os.Getenv("WEBHOOK_SECRET"); secret != "" {
+			return []domain.SigningKey{{ID: "default", Secret: secret}}, nil
+		}
+		return nil, nil
+	}
+	return parseSigningKeys("WEBHOOK_SECRETS", raw)
+}
+
+// parseSigningKeys parses a comma-separated list of "id:secret" or
+// "id:secret:expiry" entries (expiry in RFC 3339)
+func parseSigningKeys(envName, raw string) ([]domain.SigningKey, error) {
+	var keys []domain.SigningKey
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s entry must be id:secret[:expiry]", envName)
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("%s has duplicate key id %q", envName, parts[0])
+		}
+		seen[parts[0]] = true
+
+		key := domain.SigningKey{ID: parts[0], Secret: parts[1]}
+		if len(parts) == 3 {
+			expiresAt, err := time.Parse(time.RFC3339, parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("%s key %q has invalid expiry: %w", envName, parts[0], err)
+			}
+			key.ExpiresAt = expiresAt
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// loadEndpoints parses WEBHOOK_ENDPOINTS as a comma-separated list of
+// "path=scheme" entries. Each endpoint uses WEBHOOK_SECRETS_<SCHEME> when set
+// (e.g. WEBHOOK_SECRETS_GITHUB) and the default keys otherwise
+func loadEndpoints(defaultKeys []domain.SigningKey) ([]EndpointConfig, error) {
+	raw := os.Getenv("WEBHOOK_ENDPOINTS")
+	if raw == "" {
+		raw = "/=" + getEnvOrDefault("SIGNATURE_SCHEME", domain.SchemeHMAC)
+	}
+
+	var endpoints []EndpointConfig
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		path, scheme, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(path, "/") || scheme == "" {
+			return nil, fmt.Errorf("WEBHOOK_ENDPOINTS entry must be /path=scheme, got %q", entry)
+		}
+		if seen[path] {
+			return nil, fmt.Errorf("WEBHOOK_ENDPOINTS has duplicate path %q", path)
+		}
+		seen[path] = true
+
+		keys, err := loadSchemeKeys(scheme, defaultKeys)
+		if err != nil {
+			return nil, err
+		}
+		// Asymmetric schemes verify against PublicKeys instead of shared secrets
+		if len(keys) == 0 && scheme != domain.SchemeEd25519 && scheme != domain.SchemeJWT {
+			return nil, fmt.Errorf("endpoint %s has no signing keys; set %s or WEBHOOK_SECRETS", path, schemeKeysEnv(scheme))
+		}
+
+		endpoints = append(endpoints, EndpointConfig{Path: path, Scheme: scheme, Keys: keys})
+	}
+
+	return endpoints, nil
+}
+
+// loadSchemeKeys returns WEBHOOK_SECRETS_<SCHEME> when set, else defaultKeys
+func loadSchemeKeys(scheme string, defaultKeys []domain.SigningKey) ([]domain.SigningKey, error) {
+	envName := schemeKeysEnv(scheme)
+	if override := os.Getenv(envName); override != "" {
+		return parseSigningKeys(envName, override)
+	}
+	return defaultKeys, nil
+}
+
+// schemeKeysEnv names the per-scheme secrets variable, e.g. WEBHOOK_SECRETS_GITHUB
+func schemeKeysEnv(scheme string) string {
+	return "WEBHOOK_SECRETS_" + strings.ToUpper(strings.ReplaceAll(scheme, "-", "_"))
+}
+
+// loadPublicKeys reads every file listed in WEBHOOK_PUBLIC_KEYS into one keyring
+func loadPublicKeys() (domain.Ed25519Keyring, error) {
+	return loadPublicKeyFiles("WEBHOOK_PUBLIC_KEYS", getEnvList("WEBHOOK_PUBLIC_KEYS"))
+}
+
+// loadPublicKeyFiles reads PEM or JWK/JWKS files into one keyring; label
+// names the setting in errors. PEM keys are named after their file (without extension)
+func loadPublicKeyFiles(label string, paths []string) (domain.Ed25519Keyring, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	keyring := make(domain.Ed25519Keyring)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		keys, err := domain.ParseEd25519PublicKeys(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", label, path, err)
+		}
+		for id, key := range keys {
+			if _, exists := keyring[id]; exists {
+				return nil, fmt.Errorf("%s has duplicate key id %q", label, id)
+			}
+			keyring[id] = key
+		}
+	}
+
+	return keyring, nil
+}
+
+// loadJWKS reads a JWKS document from a file or an http(s) URL; label names
+// the setting in errors
+func loadJWKS(label, source string) (map[string]crypto.PublicKey, error) {
+	if source == "" {
+		return nil, nil
+	}
+
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s: %s returned %s", label, source, resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(source); err != nil {
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+	}
+
+	keys, err := domain.ParseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", label, source, err)
+	}
+	return keys, nil
+}
+
+// loadJWTPolicy reads JWT_ISSUER, JWT_AUDIENCE, JWT_LEEWAY and
+// JWT_REQUIRED_CLAIMS (comma-separated claim=value pairs)
+func loadJWTPolicy() (domain.JWTPolicy, error) {
+	policy := domain.JWTPolicy{
+		Issuer:         os.Getenv("JWT_ISSUER"),
+		Audience:       os.Getenv("JWT_AUDIENCE"),
+		RequiredClaims: make(map[string]string),
+	}
+
+	var err error
+	if policy.Leeway, err = getEnvDuration("JWT_LEEWAY", 30*time.Second); err != nil {
+		return policy, err
+	}
+
+	for _, entry := range getEnvList("JWT_REQUIRED_CLAIMS") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return policy, fmt.Errorf("JWT_REQUIRED_CLAIMS entry must be claim=value, got %q", entry)
+		}
+		policy.RequiredClaims[name] = value
+	}
+
+	return policy, nil
+}
+
+// loadRecordSchema compiles the JSON Schema at path, falling back to the
+// built-in schema, so a broken schema stops startup instead of every request
+func loadRecordSchema(path string) (domain.RecordValidator, error) {
+	document := domain.DefaultRecordSchema
+	if path != "" {
+		var err error
+		if document, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("RECORD_SCHEMA_FILE: %w", err)
+		}
+	}
+
+	validator, err := domain.NewJSONSchemaValidator(document)
+	if err != nil {
+		return nil, fmt.Errorf("RECORD_SCHEMA_FILE %s: %w", path, err)
+	}
+	return validator, nil
+}
+
+// loadRecordRules parses the rule set at path, falling back to the built-in rules
+func loadRecordRules(path string) ([]domain.Rule, error) {
+	document := domain.DefaultRules
+	if path != "" {
+		var err error
+		if document, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("RULES_FILE: %w", err)
+		}
+	}
+
+	rules, err := domain.ParseRules(document)
+	if err != nil {
+		return nil, fmt.Errorf("RULES_FILE %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// loadEncryptionKeys reads the keyfile at path, or returns nil when path is unset
+func loadEncryptionKeys(path string) (domain.KeyProvider, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY_FILE: %w", err)
+	}
+	keys, err := domain.ParseKeyFile(document)
+	if err != nil {
+		return nil, fmt.Errorf("ENCRYPTION_KEY_FILE %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// loadEnrichers builds the ENRICHERS chain, defaulting to every built-in enricher
+func loadEnrichers() (domain.Enrichers, error) {
+	names := getEnvList("ENRICHERS")
+	switch {
+	case len(names) == 0:
+		names = domain.DefaultEnricherNames
+	case len(names) == 1 && names[0] == "none":
+		names = nil
+	}
+
+	enrichers, err := domain.NewEnrichers(names)
+	if err != nil {
+		return nil, fmt.Errorf("ENRICHERS: %w", err)
+	}
+	return enrichers, nil
+}
+
+// tenantFile is the TENANTS_FILE document
+// Secrets stay in the environment: each tenant names the variable holding
+// its keys in WEBHOOK_SECRETS format
+type tenantFile struct {
+	Tenants []struct {
+		ID               string  `json:"id"`
+		Scheme           string  `json:"scheme"`
+		SecretsEnv       string  `json:"secretsEnv"`
+		RateLimit        float64 `json:"rateLimit"`
+		Burst            int     `json:"burst"`
+		CollectionPrefix string  `json:"collectionPrefix"`
+		// Enrichers replaces ENRICHERS for this tenant; [] disables enrichment
+		Enrichers *[]string `json:"enrichers"`
+		// PublicKeys lists the PEM or JWK/JWKS files of an ed25519 tenant
+		PublicKeys []string `json:"publicKeys"`
+		// JWKS is the file or URL of a jwt tenant's keys; RequiredClaims are
+		// added to JWT_REQUIRED_CLAIMS, e.g. to pin the sender's service account
+		JWKS           string            `json:"jwks"`
+		RequiredClaims map[string]string `json:"requiredClaims"`
+	} `json:"tenants"`
+}
+
+// loadTenants reads the tenant registry from a JSON file
+// Public keys and JWKS are loaded per tenant, never from the deployment's
+// settings, so one tenant's credentials cannot write as another
+func loadTenants(path string, jwtPolicy domain.JWTPolicy) ([]domain.Tenant, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("TENANTS_FILE: %w", err)
+	}
+
+	var file tenantFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("TENANTS_FILE %s: %w", path, err)
+	}
+
+	var tenants []domain.Tenant
+	seen := make(map[string]bool)
+	jwksClaims := make(map[string]map[string]bool) // JWKS source -> required claims in use
+	for _, entry := range file.Tenants {
+		if entry.ID == "" || strings.ContainsAny(entry.ID, "/ ") {
+			return nil, fmt.Errorf("TENANTS_FILE tenant id %q must be non-empty without slashes or spaces", entry.ID)
+		}
+		if seen[entry.ID] {
+			return nil, fmt.Errorf("TENANTS_FILE has duplicate tenant id %q", entry.ID)
+		}
+		seen[entry.ID] = true
+
+		if !collectionPrefix.MatchString(entry.CollectionPrefix) {
+			return nil, fmt.Errorf("tenant %s collectionPrefix %q may only use letters, digits, _ and - (at most 100)", entry.ID, entry.CollectionPrefix)
+		}
+
+		if entry.Scheme == "" {
+			entry.Scheme = domain.SchemeHMAC
+		}
+		if entry.RateLimit < 0 || entry.Burst < 0 {
+			return nil, fmt.Errorf("tenant %s rateLimit and burst must not be negative", entry.ID)
+		}
+		if entry.RateLimit > 0 && entry.Burst == 0 {
+			entry.Burst = int(math.Ceil(entry.RateLimit))
+		}
+
+		var keys []domain.SigningKey
+		if entry.SecretsEnv != "" {
+			if keys, err = parseSigningKeys(entry.SecretsEnv, os.Getenv(entry.SecretsEnv)); err != nil {
+				return nil, err
+			}
+		}
+		// Asymmetric schemes verify against PublicKeys instead of shared secrets
+		if len(keys) == 0 && entry.Scheme != domain.SchemeEd25519 && entry.Scheme != domain.SchemeJWT {
+			return nil, fmt.Errorf("tenant %s has no signing keys; set secretsEnv to a populated variable", entry.ID)
+		}
+
+		label := fmt.Sprintf("tenant %s publicKeys", entry.ID)
+		publicKeys, err := loadPublicKeyFiles(label, entry.PublicKeys)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Scheme == domain.SchemeEd25519 && len(publicKeys) == 0 {
+			return nil, fmt.Errorf("tenant %s uses %s and needs its own publicKeys", entry.ID, entry.Scheme)
+		}
+
+		jwks, err := loadJWKS(fmt.Sprintf("tenant %s jwks", entry.ID), entry.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		policy := tenantJWTPolicy(jwtPolicy, entry.RequiredClaims)
+		if entry.Scheme == domain.SchemeJWT {
+			if len(jwks) == 0 {
+				return nil, fmt.Errorf("tenant %s uses %s and needs its own jwks", entry.ID, entry.Scheme)
+			}
+			// Tenants trusting the same issuer keys must be told apart by their claims
+			claims := fmt.Sprint(policy.RequiredClaims)
+			if jwksClaims[entry.JWKS] == nil {
+				jwksClaims[entry.JWKS] = make(map[string]bool)
+			}
+			if jwksClaims[entry.JWKS][claims] {
+				return nil, fmt.Errorf("tenant %s shares jwks %s with another tenant; set requiredClaims that only its sender's tokens carry", entry.ID, entry.JWKS)
+			}
+			jwksClaims[entry.JWKS][claims] = true
+		}
+
+		var enrichers domain.Enrichers
+		if entry.Enrichers != nil {
+			if enrichers, err = domain.NewEnrichers(*entry.Enrichers); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", entry.ID, err)
+			}
+		}
+
+		tenants = append(tenants, domain.Tenant{
+			ID:               entry.ID,
+			Scheme:           entry.Scheme,
+			Keys:             keys,
+			RateLimit:        entry.RateLimit,
+			Burst:            entry.Burst,
+			CollectionPrefix: entry.CollectionPrefix,
+			Enrichers:        enrichers,
+			PublicKeys:       publicKeys,
+			JWKS:             jwks,
+			JWTPolicy:        policy,
+		})
+	}
+
+	return tenants, nil
+}
+
+// tenantJWTPolicy adds a tenant's required claims to the deployment's JWT policy
+func tenantJWTPolicy(base domain.JWTPolicy, claims map[string]string) domain.JWTPolicy {
+	policy := base
+	policy.RequiredClaims = make(map[string]string, len(base.RequiredClaims)+len(claims))
+	for name, value := range base.RequiredClaims {
+		policy.RequiredClaims[name] = value
+	}
+	for name, value := range claims {
+		policy.RequiredClaims[name] = value
+	}
+	return policy
+}
+
+// loadIPAllowlist parses IP_ALLOWLIST as comma-separated CIDRs or single IPs
+func loadIPAllowlist() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range getEnvList("IP_ALLOWLIST") {
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("IP_ALLOWLIST entry %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("IP_ALLOWLIST entry %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
 // getEnvOrDefault returns environment variable value or default if not set
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -41,3 +852,53 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList splits a comma-separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvDuration parses a duration (e.g. "5m") or returns default if not set
+func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration like 5m: %w", key, err)
+	}
+	return d, nil
+}
+
+// getEnvInt parses an integer or returns default if not set
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n, nil
+}
+
+// getEnvBool parses a boolean (true/false/1/0) or returns default if not set
+func getEnvBool(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false: %w", key, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// testDriver registers a SQL driver name so SQL_DRIVER validation can pass
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("not a real database")
+}
+
+func init() {
+	sql.Register("configtest", testDriver{})
+}
+
+func writeTenantsFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTenants(t *testing.T) {
+	// Arrange
+	t.Setenv("TENANT_SECRETS", "k1:secret1")
+	path := writeTenantsFile(t, `{"tenants":[
+		{"id":"blog","secretsEnv":"TENANT_SECRETS","rateLimit":2.5,"collectionPrefix":"blog_"},
+		{"id":"shop","scheme":"github","secretsEnv":"TENANT_SECRETS","enrichers":[]}]}`)
+
+	// Act
+	tenants, err := loadTenants(path, domain.JWTPolicy{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tenants) != 2 {
+		t.Fatalf("Expected 2 tenants, got %d", len(tenants))
+	}
+	blog, shop := tenants[0], tenants[1]
+	if blog.Scheme != domain.SchemeHMAC || blog.Burst != 3 || blog.CollectionPrefix != "blog_" || len(blog.Keys) != 1 || blog.Enrichers != nil {
+		t.Errorf("Expected blog to default to hmac with a burst of 3, got %+v", blog)
+	}
+	if shop.Scheme != domain.SchemeGitHub || shop.Enrichers == nil || len(shop.Enrichers) != 0 {
+		t.Errorf("Expected shop to use github with enrichment off, got %+v", shop)
+	}
+}
+
+func TestLoadTenantsRejectsInvalidEntries(t *testing.T) {
+	t.Setenv("TENANT_SECRETS", "k1:secret1")
+	t.Setenv("EMPTY_SECRETS", "")
+
+	cases := map[string]struct {
+		tenants string
+		want    string
+	}{
+		"id with slash":        {`{"id":"a/b","secretsEnv":"TENANT_SECRETS"}`, "without slashes"},
+		"duplicate id":         {`{"id":"a","secretsEnv":"TENANT_SECRETS"},{"id":"a","secretsEnv":"TENANT_SECRETS"}`, "duplicate tenant id"},
+		"prefix with slash":    {`{"id":"a","secretsEnv":"TENANT_SECRETS","collectionPrefix":"tenants/a_"}`, "collectionPrefix"},
+		"prefix with dot":      {`{"id":"a","secretsEnv":"TENANT_SECRETS","collectionPrefix":"a.b"}`, "collectionPrefix"},
+		"negative rate":        {`{"id":"a","secretsEnv":"TENANT_SECRETS","rateLimit":-1}`, "must not be negative"},
+		"no secrets":           {`{"id":"a","secretsEnv":"EMPTY_SECRETS"}`, "no signing keys"},
+		"ed25519 without keys": {`{"id":"a","scheme":"ed25519"}`, "needs its own publicKeys"},
+		"jwt without jwks":     {`{"id":"a","scheme":"jwt"}`, "needs its own jwks"},
+		"unknown enricher":     {`{"id":"a","secretsEnv":"TENANT_SECRETS","enrichers":["geoip"]}`, "unknown enricher"},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		path := writeTenantsFile(t, `{"tenants":[`+tc.tenants+`]}`)
+
+		// Act
+		_, err := loadTenants(path, domain.JWTPolicy{})
+
+		// Assert
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestLoadEndpointsSelectsSchemes(t *testing.T) {
+	defaultKeys := []domain.SigningKey{{ID: "default", Secret: "secret"}}
+
+	cases := map[string]struct {
+		endpoints string
+		scheme    string
+		github    string
+		keys      []domain.SigningKey
+		want      []string // path=scheme/first key id
+		wantErr   string
+	}{
+		"default":            {keys: defaultKeys, want: []string{"/=hmac/default"}},
+		"signature scheme":   {scheme: "stripe", keys: defaultKeys, want: []string{"/=stripe/default"}},
+		"per-scheme secrets": {endpoints: "/hooks=hmac, /github=github", github: "gh:ghsecret", keys: defaultKeys, want: []string{"/hooks=hmac/default", "/github=github/gh"}},
+		"jwt without keys":   {endpoints: "/jwt=jwt", want: []string{"/jwt=jwt/"}},
+		"missing slash":      {endpoints: "hooks=hmac", keys: defaultKeys, wantErr: "must be /path=scheme"},
+		"duplicate path":     {endpoints: "/a=hmac,/a=github", keys: defaultKeys, wantErr: "duplicate path"},
+		"hmac without keys":  {endpoints: "/a=hmac", wantErr: "no signing keys"},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		t.Setenv("WEBHOOK_ENDPOINTS", tc.endpoints)
+		t.Setenv("SIGNATURE_SCHEME", tc.scheme)
+		t.Setenv("WEBHOOK_SECRETS_GITHUB", tc.github)
+
+		// Act
+		endpoints, err := loadEndpoints(tc.keys)
+
+		// Assert
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: expected an error containing %q, got %v", name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		var got []string
+		for _, endpoint := range endpoints {
+			keyID := ""
+			if len(endpoint.Keys) > 0 {
+				keyID = endpoint.Keys[0].ID
+			}
+			got = append(got, endpoint.Path+"="+endpoint.Scheme+"/"+keyID)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestConfigSinks(t *testing.T) {
+	cases := map[string]struct {
+		cfg      Config
+		want     []string
+		required []string
+		wantErr  string
+	}{
+		"firestore": {
+			cfg:      Config{StorageBackend: StorageFirestore},
+			want:     []string{StorageFirestore},
+			required: []string{StorageFirestore},
+		},
+		"every sink": {
+			cfg:      Config{StorageBackend: StorageBoth, FirebaseDatabaseURL: "https://db", ArchiveFile: "a.jsonl", SQLDriver: "configtest", SQLDSN: "dsn", BestEffortSinks: []string{StorageRTDB, SinkArchive}},
+			want:     []string{StorageFirestore, StorageRTDB, SinkArchive, SinkSQL},
+			required: []string{StorageFirestore, SinkSQL},
+		},
+		"unknown backend":       {cfg: Config{StorageBackend: "s3"}, wantErr: "STORAGE_BACKEND"},
+		"rtdb without url":      {cfg: Config{StorageBackend: StorageRTDB}, wantErr: "FIREBASE_DATABASE_URL"},
+		"driver without dsn":    {cfg: Config{StorageBackend: StorageFirestore, SQLDriver: "configtest"}, wantErr: "set together"},
+		"driver not linked":     {cfg: Config{StorageBackend: StorageFirestore, SQLDriver: "pgx", SQLDSN: "dsn"}, wantErr: "not linked"},
+		"unsafe table":          {cfg: Config{StorageBackend: StorageFirestore, SQLDriver: "configtest", SQLDSN: "dsn", SQLTable: "a; DROP TABLE b"}, wantErr: "SQL_TABLE"},
+		"best effort alone":     {cfg: Config{StorageBackend: StorageFirestore, BestEffortSinks: []string{StorageFirestore}}, wantErr: "more than one sink"},
+		"best effort unknown":   {cfg: Config{StorageBackend: StorageFirestore, ArchiveFile: "a.jsonl", BestEffortSinks: []string{SinkSQL}}, wantErr: "must be configured sinks"},
+		"nothing left required": {cfg: Config{StorageBackend: StorageFirestore, ArchiveFile: "a.jsonl", BestEffortSinks: []string{StorageFirestore, SinkArchive}}, wantErr: "at least one sink required"},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		cfg := tc.cfg
+		cfg.FirebaseProjectID = "project"
+		if cfg.SQLTable == "" {
+			cfg.SQLTable = DefaultSQLTable
+		}
+
+		// Act
+		err := cfg.validateStorage()
+
+		// Assert
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: expected an error containing %q, got %v", name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		if got := cfg.Sinks(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected sinks %v, got %v", name, tc.want, got)
+		}
+		var required []string
+		for _, sink := range cfg.Sinks() {
+			if cfg.IsRequiredSink(sink) {
+				required = append(required, sink)
+			}
+		}
+		if !slices.Equal(required, tc.required) {
+			t.Errorf("%s: expected required sinks %v, got %v", name, tc.required, required)
+		}
+	}
+}
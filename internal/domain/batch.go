@@ -0,0 +1,52 @@
+package domain
+
+import "context"
+
+// EventTypeBatch marks a payload carrying many records in Records instead of Data
+const EventTypeBatch = "analytics_batch"
+
+// MaxBatchRecords caps a batch delivery (Firestore's limit for one atomic batch)
+const MaxBatchRecords = 500
+
+// Per-record outcomes reported for batch deliveries
+const (
+	RecordAccepted  = "accepted"
+	RecordDuplicate = "duplicate"
+	RecordInvalid   = "invalid"
+)
+
+// RecordOutcome reports what happened to one record of a batch
+type RecordOutcome struct {
+	Index      int         `json:"index"`
+	RequestID  string      `json:"requestId,omitempty"`
+	Status     string      `json:"status"`
+	Reason     string      `json:"reason,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// ProcessResult reports the outcome of one delivery
+type ProcessResult struct {
+	// Records holds one outcome per record of a batch, in payload order
+	// It is nil for single-record events
+	Records []RecordOutcome
+}
+
+// Count returns how many records ended with the given status
+func (r *ProcessResult) Count(status string) int {
+	if r == nil {
+		return 0
+	}
+	n := 0
+	for _, outcome := range r.Records {
+		if outcome.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// BatchWriter is implemented by writers that can store many records in one call
+// Writers without it receive the records one Write at a time
+type BatchWriter interface {
+	WriteBatch(ctx context.Context, records []AnalyticsRecord) error
+}
@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// CloudEvents 1.0 HTTP binding (https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/http-protocol-binding.md)
+const (
+	CloudEventsSpecVersion       = "1.0"
+	CloudEventsContentType       = "application/cloudevents+json"
+	CloudEventsBatchContentType  = "application/cloudevents-batch+json"
+	CloudEventsSpecVersionHeader = "ce-specversion"
+	CloudEventsIDHeader          = "ce-id"
+	CloudEventsSourceHeader      = "ce-source"
+	CloudEventsTypeHeader        = "ce-type"
+	CloudEventsTimeHeader        = "ce-time"
+
+	// CloudEventsSchemaVersionHeader carries the schemaversion extension attribute
+	CloudEventsSchemaVersionHeader = "ce-schemaversion"
+)
+
+// RuleBatchEventType is reported for a batched CloudEvent whose type is not
+// EventTypeCreated
+const RuleBatchEventType = "batchEventType"
+
+// CloudEventAttributes are the context attributes kept with a record that
+// arrived as a CloudEvent
+type CloudEventAttributes struct {
+	ID     string
+	Source string
+	Type   string
+	Time   string // RFC 3339 as sent, empty when the producer set none
+}
+
+// cloudEvent is the structured-mode JSON format of one event
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+
+	// SchemaVersion is the schemaversion extension; json.Number accepts
+	// both the JSON number and the string form
+	SchemaVersion json.Number `json:"schemaversion"`
+}
+
+// CloudEventsParser maps CloudEvents in binary, structured and batch content
+// modes onto WebhookPayload. The event data is one AnalyticsRecord; a batch
+// becomes an EventTypeBatch payload. The data is upcast from the version in
+// the schemaversion extension attribute. In strict Mode, unknown record
+// fields are rejected; extension attributes are always allowed
+type CloudEventsParser struct {
+	Schemas *SchemaVersions
+	Mode    DecodeMode
+}
+
+// Parse implements PayloadParser
+func (p CloudEventsParser) Parse(body []byte, headers Headers) (*WebhookPayload, error) {
+	// Binary mode: attributes in ce-* headers, the body is the data
+	if headers.Get(CloudEventsSpecVersionHeader) != "" {
+		return cloudEvent{
+			SpecVersion:     headers.Get(CloudEventsSpecVersionHeader),
+			ID:              headers.Get(CloudEventsIDHeader),
+			Source:          headers.Get(CloudEventsSourceHeader),
+			Type:            headers.Get(CloudEventsTypeHeader),
+			Time:            headers.Get(CloudEventsTimeHeader),
+			DataContentType: headers.Get(ContentTypeHeader),
+			Data:            body,
+			SchemaVersion:   json.Number(headers.Get(CloudEventsSchemaVersionHeader)),
+		}.payload(p, "")
+	}
+
+	if p.Mode == DecodeStrict {
+		if err := CheckStrictJSON(body); err != nil {
+			return nil, err
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(headers.Get(ContentTypeHeader))
+	if mediaType == CloudEventsBatchContentType {
+		var events []cloudEvent
+		if err := json.Unmarshal(body, &events); err != nil {
+			return nil, fmt.Errorf("cloudevents batch: %w", DecodeError(err, ""))
+		}
+
+		// A batch is written as new records, so an update, delete or unknown
+		// type inside one would be stored as a create; reject them all at once
+		var violations []Violation
+		for i, event := range events {
+			if event.Type != EventTypeCreated {
+				violations = append(violations, Violation{
+					Pointer: fmt.Sprintf("/%d/type", i),
+					Rule:    RuleBatchEventType,
+					Message: fmt.Sprintf("batches may only carry %s events, got %q", EventTypeCreated, event.Type),
+				})
+			}
+		}
+		if err := violationsError(violations); err != nil {
+			return nil, err
+		}
+
+		batch := &WebhookPayload{EventType: EventTypeBatch, Records: make([]AnalyticsRecord, 0, len(events))}
+		for i, event := range events {
+			payload, err := event.payload(p, fmt.Sprintf("/%d/data", i))
+			if err != nil {
+				return nil, fmt.Errorf("cloudevents batch event %d: %w", i, err)
+			}
+			batch.Records = append(batch.Records, payload.Data)
+		}
+		return batch, nil
+	}
+
+	var event cloudEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("cloudevent: %w", DecodeError(err, ""))
+	}
+	return event.payload(p, "/data")
+}
+
+// payload checks the required attributes and decodes the data, which is at
+// pointer in the body
+func (e cloudEvent) payload(p CloudEventsParser, pointer string) (*WebhookPayload, error) {
+	if e.SpecVersion != CloudEventsSpecVersion {
+		return nil, fmt.Errorf("%w: unsupported cloudevents specversion %q", ErrInvalidPayload, e.SpecVersion)
+	}
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return nil, fmt.Errorf("%w: cloudevent requires id, source and type", ErrInvalidPayload)
+	}
+
+	payload := &WebhookPayload{EventType: e.Type}
+	if e.Time != "" {
+		eventTime, err := time.Parse(time.RFC3339, e.Time)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cloudevent time: %v", ErrInvalidPayload, err)
+		}
+		payload.Timestamp = eventTime.UnixMilli()
+	}
+
+	if !isJSONMediaType(e.DataContentType) {
+		return nil, fmt.Errorf("%w: unsupported cloudevent datacontenttype %q", ErrInvalidPayload, e.DataContentType)
+	}
+	data := []byte(e.Data)
+	if e.DataBase64 != "" {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(e.DataBase64); err != nil {
+			return nil, fmt.Errorf("%w: cloudevent data_base64: %v", ErrInvalidPayload, err)
+		}
+		if pointer != "" {
+			pointer += "_base64"
+		}
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: cloudevent has no data", ErrInvalidPayload)
+	}
+	version := 0
+	if e.SchemaVersion != "" {
+		v, err := e.SchemaVersion.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: cloudevent schemaversion %q", ErrInvalidPayload, e.SchemaVersion)
+		}
+		version = int(v)
+	}
+	if p.Mode == DecodeStrict && pointer == "" {
+		// Binary mode: the body is the data and has not been checked yet
+		if err := CheckStrictJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	record, err := p.Schemas.DecodeRecord(data, version)
+	if err != nil {
+		return nil, fmt.Errorf("cloudevent data: %w", PrefixViolations(err, pointer))
+	}
+	if err := violationsError(StrictRecordViolations(p.Mode, pointer, record)); err != nil {
+		return nil, err
+	}
+	payload.Data = record
+	payload.SchemaVersion = record.SchemaVersion
+
+	payload.Data.CloudEvent = &CloudEventAttributes{ID: e.ID, Source: e.Source, Type: e.Type, Time: e.Time}
+	return payload, nil
+}
+
+// isJSONMediaType accepts an absent content type, application/json and +json suffixes
+func isJSONMediaType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const ceRecord = `{"requestId":"req_123","query":"Do you have Go?","timestamp":1700000000}`
+
+func TestPayloadFormatsCloudEvents(t *testing.T) {
+	formats := NewDefaultPayloadFormats(DecodePermissive)
+
+	binary := http.Header{}
+	binary.Set(ContentTypeHeader, "application/json")
+	binary.Set(CloudEventsSpecVersionHeader, "1.0")
+	binary.Set(CloudEventsIDHeader, "evt-1")
+	binary.Set(CloudEventsSourceHeader, "//lambda/cv-chatbot")
+	binary.Set(CloudEventsTypeHeader, "com.cv.analytics.created")
+	binary.Set(CloudEventsTimeHeader, "2023-11-14T22:13:20Z")
+
+	structured := http.Header{}
+	structured.Set(ContentTypeHeader, CloudEventsContentType+"; charset=utf-8")
+
+	cases := map[string]struct {
+		body    string
+		headers http.Header
+	}{
+		"binary mode": {ceRecord, binary},
+		"structured mode": {`{"specversion":"1.0","id":"evt-1","source":"//lambda/cv-chatbot",` +
+			`"type":"com.cv.analytics.created","time":"2023-11-14T22:13:20Z","data":` + ceRecord + `}`, structured},
+		"structured base64 data": {`{"specversion":"1.0","id":"evt-1","source":"//lambda/cv-chatbot",` +
+			`"type":"com.cv.analytics.created","time":"2023-11-14T22:13:20Z",` +
+			`"data_base64":"eyJyZXF1ZXN0SWQiOiJyZXFfMTIzIiwicXVlcnkiOiJEbyB5b3UgaGF2ZSBHbz8iLCJ0aW1lc3RhbXAiOjE3MDAwMDAwMDB9"}`, structured},
+	}
+
+	for name, tc := range cases {
+		// Act
+		payload, err := formats.Parse([]byte(tc.body), tc.headers)
+
+		// Assert
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		if payload.EventType != "com.cv.analytics.created" || payload.Timestamp != 1700000000000 {
+			t.Errorf("%s: expected type and time mapped onto payload, got %+v", name, payload)
+		}
+		ce := payload.Data.CloudEvent
+		if payload.Data.RequestID != "req_123" || ce == nil || ce.ID != "evt-1" || ce.Source != "//lambda/cv-chatbot" || ce.Time != "2023-11-14T22:13:20Z" {
+			t.Errorf("%s: expected record with cloudevent attributes, got %+v / %+v", name, payload.Data, ce)
+		}
+	}
+}
+
+func TestPayloadFormatsCloudEventsBatch(t *testing.T) {
+	// Arrange
+	headers := http.Header{}
+	headers.Set(ContentTypeHeader, CloudEventsBatchContentType)
+	body := `[{"specversion":"1.0","id":"evt-1","source":"s","type":"analytics_record_created","data":` + ceRecord + `},` +
+		`{"specversion":"1.0","id":"evt-2","source":"s","type":"analytics_record_created","data":{"requestId":"req_456"}}]`
+
+	// Act
+	payload, err := NewDefaultPayloadFormats(DecodePermissive).Parse([]byte(body), headers)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if payload.EventType != EventTypeBatch || len(payload.Records) != 2 {
+		t.Fatalf("Expected a batch of 2 records, got %+v", payload)
+	}
+	if payload.Records[1].RequestID != "req_456" || payload.Records[1].CloudEvent.ID != "evt-2" {
+		t.Errorf("Expected each record to keep its own event id, got %+v", payload.Records[1])
+	}
+}
+
+func TestPayloadFormatsCloudEventsBatchRejectsOtherEventTypes(t *testing.T) {
+	// Arrange
+	headers := http.Header{}
+	headers.Set(ContentTypeHeader, CloudEventsBatchContentType)
+	body := `[{"specversion":"1.0","id":"evt-1","source":"s","type":"analytics_record_created","data":` + ceRecord + `},` +
+		`{"specversion":"1.0","id":"evt-2","source":"s","type":"analytics_record_updated","data":{"requestId":"req_123"}},` +
+		`{"specversion":"1.0","id":"evt-3","source":"s","type":"t","data":{"requestId":"req_456"}}]`
+
+	// Act
+	_, err := NewDefaultPayloadFormats(DecodePermissive).Parse([]byte(body), headers)
+
+	// Assert
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if len(validationErr.Violations) != 2 {
+		t.Fatalf("Expected a violation per non-create event, got %+v", validationErr.Violations)
+	}
+	for i, pointer := range []string{"/1/type", "/2/type"} {
+		if v := validationErr.Violations[i]; v.Pointer != pointer || v.Rule != RuleBatchEventType {
+			t.Errorf("Expected %s violation at %s, got %+v", RuleBatchEventType, pointer, v)
+		}
+	}
+}
+
+func TestPayloadFormatsCloudEventsInvalid(t *testing.T) {
+	structured := func(body string) (string, http.Header) {
+		headers := http.Header{}
+		headers.Set(ContentTypeHeader, CloudEventsContentType)
+		return body, headers
+	}
+
+	cases := map[string]string{
+		"old specversion": `{"specversion":"0.3","id":"1","source":"s","type":"t","data":{}}`,
+		"missing source":  `{"specversion":"1.0","id":"1","type":"t","data":{}}`,
+		"bad time":        `{"specversion":"1.0","id":"1","source":"s","type":"t","time":"yesterday","data":{}}`,
+		"xml data":        `{"specversion":"1.0","id":"1","source":"s","type":"t","datacontenttype":"application/xml","data":"<a/>"}`,
+		"no data":         `{"specversion":"1.0","id":"1","source":"s","type":"t"}`,
+	}
+
+	for name, raw := range cases {
+		body, headers := structured(raw)
+
+		_, err := NewDefaultPayloadFormats(DecodePermissive).Parse([]byte(body), headers)
+
+		if !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: expected ErrInvalidPayload, got %v", name, err)
+		}
+	}
+}
+
+func TestPayloadFormatsFallsBackToEnvelope(t *testing.T) {
+	// Arrange
+	headers := http.Header{}
+	headers.Set(ContentTypeHeader, "application/json")
+	body := `{"eventType":"analytics_event","timestamp":1700000000,"data":` + ceRecord + `}`
+
+	// Act
+	payload, err := NewDefaultPayloadFormats(DecodePermissive).Parse([]byte(body), headers)
+
+	// Assert
+	if err != nil || payload.EventType != "analytics_event" || payload.Data.RequestID != "req_123" {
+		t.Errorf("Expected envelope to parse, got %+v (%v)", payload, err)
+	}
+	if payload.Data.CloudEvent != nil {
+		t.Errorf("Expected no cloudevent attributes on an envelope")
+	}
+}
@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncodingHeader lists the codings applied to the request body, in order
+const ContentEncodingHeader = "Content-Encoding"
+
+// DefaultMaxDecodedBytes caps a decompressed body when no limit is configured
+const DefaultMaxDecodedBytes = 10 << 20
+
+// ContentDecoder implements BodyDecoder for gzip, deflate and zstd bodies
+// Every step is capped at maxBytes of output, so a small compressed body
+// cannot expand into an unbounded allocation (decompression bomb)
+type ContentDecoder struct {
+	maxBytes int64
+}
+
+// NewContentDecoder creates a decoder that rejects output above maxBytes
+func NewContentDecoder(maxBytes int64) *ContentDecoder {
+	return &ContentDecoder{maxBytes: maxBytes}
+}
+
+// Decode undoes the codings listed in Content-Encoding, last applied first
+func (d *ContentDecoder) Decode(body []byte, headers Headers) ([]byte, error) {
+	codings := strings.Split(headers.Get(ContentEncodingHeader), ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+
+		var err error
+		if body, err = d.decode(coding, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// DecodeStream wraps body in readers that undo the codings listed in
+// Content-Encoding, for bodies too large to buffer. The decoded stream is
+// capped at maxBytes like Decode; an unencoded body is returned as it is
+// Errors while reading wrap ErrMalformedEncoding or ErrDecodedTooLarge
+func (d *ContentDecoder) DecodeStream(body io.Reader, headers Headers) (io.ReadCloser, error) {
+	stream := &decodedStream{reader: body, remaining: d.maxBytes}
+	codings := strings.Split(headers.Get(ContentEncodingHeader), ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+
+		reader, err := d.open(coding, stream.reader)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		stream.reader = reader
+		stream.closers = append(stream.closers, reader)
+	}
+	if len(stream.closers) == 0 {
+		return io.NopCloser(body), nil
+	}
+	return stream, nil
+}
+
+// decode applies one coding with the output cap
+func (d *ContentDecoder) decode(coding string, body []byte) ([]byte, error) {
+	reader, err := d.open(coding, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// Read one byte past the cap to tell "exactly at the limit" from "over it"
+	decoded, err := io.ReadAll(io.LimitReader(reader, d.maxBytes+1))
+	if err != nil {
+		return nil, decodingError(coding, err)
+	}
+	if int64(len(decoded)) > d.maxBytes {
+		return nil, fmt.Errorf("%w: %s body expands beyond %d bytes", ErrDecodedTooLarge, coding, d.maxBytes)
+	}
+	return decoded, nil
+}
+
+// open returns a reader that undoes one coding of r
+func (d *ContentDecoder) open(coding string, r io.Reader) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	var err error
+
+	switch coding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r)
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but some clients send raw DEFLATE
+		buffered := bufio.NewReader(r)
+		if header, peekErr := buffered.Peek(2); peekErr == nil && zlibHeader(header) {
+			reader, err = zlib.NewReader(buffered)
+		} else {
+			reader = flate.NewReader(buffered)
+		}
+	case "zstd":
+		var decoder *zstd.Decoder
+		decoder, err = zstd.NewReader(r,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(uint64(d.maxBytes)),
+			zstd.WithDecoderMaxWindow(uint64(d.maxBytes)))
+		if err == nil {
+			reader = decoder.IOReadCloser()
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, coding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformedEncoding, coding, err)
+	}
+	return reader, nil
+}
+
+// zlibHeader reports whether header starts a zlib stream without a preset
+// dictionary (RFC 1950): deflate method, and a check value divisible by 31
+func zlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && header[1]&0x20 == 0 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// decodingError classifies an error from reading a decoded body
+func decodingError(coding string, err error) error {
+	// zstd enforces the cap itself when the frame declares a larger window
+	if errors.Is(err, zstd.ErrWindowSizeExceeded) || errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return fmt.Errorf("%w: %s: %v", ErrDecodedTooLarge, coding, err)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrMalformedEncoding, coding, err)
+}
+
+// decodedStream reads a decoded body up to a byte limit
+type decodedStream struct {
+	reader    io.Reader
+	closers   []io.Closer
+	remaining int64
+}
+
+// Read implements io.Reader
+func (s *decodedStream) Read(p []byte) (int, error) {
+	if s.remaining < 0 {
+		return 0, fmt.Errorf("%w: body expands beyond the limit", ErrDecodedTooLarge)
+	}
+	// Allow one byte past the cap to tell "exactly at the limit" from "over it"
+	if int64(len(p)) > s.remaining+1 {
+		p = p[:s.remaining+1]
+	}
+	n, err := s.reader.Read(p)
+	s.remaining -= int64(n)
+	if s.remaining < 0 {
+		return n - 1, fmt.Errorf("%w: body expands beyond the limit", ErrDecodedTooLarge)
+	}
+	if err != nil && err != io.EOF {
+		return n, decodingError("body", err)
+	}
+	return n, err
+}
+
+// Close closes the decoders in the reverse of the order they were opened
+func (s *decodedStream) Close() error {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i].Close()
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func encodingHeaders(coding string) http.Header {
+	headers := http.Header{}
+	headers.Set(ContentEncodingHeader, coding)
+	return headers
+}
+
+func TestContentDecoderRoundTrip(t *testing.T) {
+	payload := []byte(`{"eventType":"analytics_event","data":{"requestId":"req_123"}}`)
+	decoder := NewContentDecoder(1024)
+
+	cases := map[string]struct {
+		body   []byte
+		header string
+	}{
+		"identity":    {payload, ""},
+		"gzip":        {compress(t, "gzip", payload), "gzip"},
+		"deflate":     {compress(t, "deflate", payload), "deflate"},
+		"raw deflate": {compress(t, "raw-deflate", payload), "deflate"},
+		"zstd":        {compress(t, "zstd", payload), "zstd"},
+		"stacked":     {compress(t, "zstd", compress(t, "gzip", payload)), "gzip, zstd"},
+	}
+
+	for name, tc := range cases {
+		// Act
+		decoded, err := decoder.Decode(tc.body, encodingHeaders(tc.header))
+
+		// Assert
+		if err != nil || !bytes.Equal(decoded, payload) {
+			t.Errorf("%s: expected original payload, got %q (%v)", name, decoded, err)
+		}
+	}
+}
+
+func TestContentDecoderRejectsBadBodies(t *testing.T) {
+	bomb := bytes.Repeat([]byte{'a'}, 1<<20)
+	decoder := NewContentDecoder(1024)
+
+	cases := map[string]struct {
+		body   []byte
+		header string
+		want   error
+	}{
+		"gzip bomb":     {compress(t, "gzip", bomb), "gzip", ErrDecodedTooLarge},
+		"zstd bomb":     {compress(t, "zstd", bomb), "zstd", ErrDecodedTooLarge},
+		"truncated":     {compress(t, "gzip", bomb)[:20], "gzip", ErrMalformedEncoding},
+		"not gzip":      {[]byte(`{"plain":"json"}`), "gzip", ErrMalformedEncoding},
+		"unknown codec": {[]byte(`{}`), "br", ErrUnsupportedEncoding},
+	}
+
+	for name, tc := range cases {
+		// Act
+		_, err := decoder.Decode(tc.body, encodingHeaders(tc.header))
+
+		// Assert
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestContentDecoderDecodeStream(t *testing.T) {
+	payload := []byte("{\"requestId\":\"r1\"}\n{\"requestId\":\"r2\"}\n")
+	bomb := bytes.Repeat([]byte{'a'}, 1<<20)
+	decoder := NewContentDecoder(1024)
+
+	cases := map[string]struct {
+		body   []byte
+		header string
+		want   error
+	}{
+		"identity":      {payload, "", nil},
+		"gzip":          {compress(t, "gzip", payload), "gzip", nil},
+		"raw deflate":   {compress(t, "raw-deflate", payload), "deflate", nil},
+		"stacked":       {compress(t, "zstd", compress(t, "gzip", payload)), "gzip, zstd", nil},
+		"gzip bomb":     {compress(t, "gzip", bomb), "gzip", ErrDecodedTooLarge},
+		"truncated":     {compress(t, "gzip", bomb)[:20], "gzip", ErrMalformedEncoding},
+		"unknown codec": {payload, "br", ErrUnsupportedEncoding},
+	}
+
+	for name, tc := range cases {
+		// Act
+		stream, err := decoder.DecodeStream(bytes.NewReader(tc.body), encodingHeaders(tc.header))
+		var decoded []byte
+		if err == nil {
+			decoded, err = io.ReadAll(stream)
+			stream.Close()
+		}
+
+		// Assert
+		if tc.want != nil {
+			if !errors.Is(err, tc.want) {
+				t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(decoded, payload) {
+			t.Errorf("%s: expected original payload, got %q (%v)", name, decoded, err)
+		}
+	}
+}
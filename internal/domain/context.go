@@ -0,0 +1,40 @@
+package domain
+
+import "context"
+
+// senderKey is the context key for the authenticated transport identity
+type senderKey struct{}
+
+// WithSender returns a context carrying the sender identity verified by the
+// transport (e.g. a client certificate subject)
+func WithSender(ctx context.Context, sender string) context.Context {
+	return context.WithValue(ctx, senderKey{}, sender)
+}
+
+// SenderFromContext returns the verified sender identity, or "" if none
+func SenderFromContext(ctx context.Context) string {
+	sender, _ := ctx.Value(senderKey{}).(string)
+	return sender
+}
+
+// tenantKey is the context key for the resolved tenant
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant the request was routed to
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the routed tenant, or nil for single-sender deployments
+func TenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey{}).(*Tenant)
+	return tenant
+}
+
+// TenantIDFromContext returns the routed tenant's ID, or "" if none
+func TenantIDFromContext(ctx context.Context) string {
+	if tenant := TenantFromContext(ctx); tenant != nil {
+		return tenant.ID
+	}
+	return ""
+}
@@ -0,0 +1,298 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DecodeMode selects how JSON bodies with unexpected content are treated
+type DecodeMode string
+
+// Decode modes
+const (
+	// DecodePermissive accepts unknown record fields and keeps them in AnalyticsRecord.Extra
+	DecodePermissive DecodeMode = "permissive"
+	// DecodeStrict rejects unknown fields, duplicate keys and trailing data
+	DecodeStrict DecodeMode = "strict"
+)
+
+// Rules reported by strict decoding
+const (
+	RuleUnknownField = "unknownField"
+	RuleDuplicateKey = "duplicateKey"
+	RuleTrailingData = "trailingData"
+)
+
+// Rules reported when a body is not well-formed JSON or a value has the wrong type
+const (
+	RuleSyntax = "syntax"
+	RuleType   = "type"
+)
+
+// recordFields are the exact JSON names of AnalyticsRecord's fields
+var recordFields = jsonFieldNames(reflect.TypeOf(AnalyticsRecord{}))
+
+// envelopeFields are the exact JSON names of the webhook envelope's fields
+var envelopeFields = jsonFieldNames(reflect.TypeOf(jsonEnvelope{}))
+
+// jsonFieldNames lists the JSON names of a struct's encoded fields
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// CheckStrictJSON rejects duplicate object keys and data after the first
+// JSON value, reporting each by JSON Pointer. encoding/json keeps the last
+// duplicate silently, so a repeated key could otherwise override a checked one
+func CheckStrictJSON(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	type frame struct {
+		object  bool
+		pointer string
+		keys    map[string]bool
+		key     string
+		wantKey bool
+		index   int
+	}
+	var stack []*frame
+	var violations []Violation
+
+	// childPointer addresses the value about to be read
+	childPointer := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			return top.pointer + "/" + escapePointer(top.key)
+		}
+		return top.pointer + "/" + strconv.Itoa(top.index)
+	}
+	// valueDone advances the parent past a completed value
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.wantKey = true
+		} else {
+			top.index++
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+
+		if n := len(stack); n > 0 && stack[n-1].object && stack[n-1].wantKey {
+			top := stack[n-1]
+			if token == json.Delim('}') {
+				stack = stack[:n-1]
+				valueDone()
+			} else {
+				key := token.(string)
+				if top.keys[key] {
+					violations = append(violations, Violation{
+						Pointer: top.pointer + "/" + escapePointer(key),
+						Rule:    RuleDuplicateKey,
+						Message: fmt.Sprintf("key %q appears more than once", key),
+					})
+				}
+				top.keys[key] = true
+				top.key = key
+				top.wantKey = false
+			}
+		} else {
+			switch token {
+			case json.Delim('{'):
+				stack = append(stack, &frame{object: true, pointer: childPointer(), keys: make(map[string]bool), wantKey: true})
+			case json.Delim('['):
+				stack = append(stack, &frame{pointer: childPointer()})
+			case json.Delim(']'):
+				stack = stack[:len(stack)-1]
+				valueDone()
+			default:
+				valueDone()
+			}
+		}
+
+		if len(stack) == 0 {
+			break
+		}
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		violations = append(violations, Violation{
+			Pointer: "",
+			Rule:    RuleTrailingData,
+			Message: fmt.Sprintf("unexpected data after the JSON value at offset %d", decoder.InputOffset()),
+		})
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// unknownFields decodes the keys of a JSON object that are not field names in
+// known. With fold false matching is exact, unlike encoding/json, so strict
+// mode reports "matchscore" even though json.Unmarshal fills MatchScore from
+// it. With fold true a key json.Unmarshal would bind is not unknown
+func unknownFields(object []byte, known map[string]bool, fold bool) (map[string]interface{}, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(object, &all); err != nil {
+		return nil, err
+	}
+
+	var extra map[string]interface{}
+	for key, raw := range all {
+		if known[key] || fold && foldMatch(key, known) {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		if extra == nil {
+			extra = make(map[string]interface{})
+		}
+		extra[key] = value
+	}
+	return extra, nil
+}
+
+// foldMatch reports whether key matches a name in known ignoring case, as
+// encoding/json matches object keys to struct fields
+func foldMatch(key string, known map[string]bool) bool {
+	for name := range known {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownFieldViolations reports each key of extra under pointer, in key order
+func unknownFieldViolations(pointer string, extra map[string]interface{}) []Violation {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	violations := make([]Violation, len(keys))
+	for i, key := range keys {
+		violations[i] = Violation{
+			Pointer: pointer + "/" + escapePointer(key),
+			Rule:    RuleUnknownField,
+			Message: fmt.Sprintf("unknown field %q", key),
+		}
+	}
+	return violations
+}
+
+// StrictEnvelopeViolations reports the unknown top-level keys of a webhook
+// envelope when mode is strict
+func StrictEnvelopeViolations(mode DecodeMode, body []byte) ([]Violation, error) {
+	if mode != DecodeStrict {
+		return nil, nil
+	}
+	extra, err := unknownFields(body, envelopeFields, false)
+	if err != nil {
+		return nil, err
+	}
+	return unknownFieldViolations("", extra), nil
+}
+
+// StrictRecordViolations reports a record's unknown fields when mode is strict
+// pointer locates the record in the body (e.g. "/data" or "/records/2")
+// Unlike Extra, a key that differs from a field name only in case is reported
+func StrictRecordViolations(mode DecodeMode, pointer string, record AnalyticsRecord) []Violation {
+	if mode != DecodeStrict {
+		return nil
+	}
+	extra := record.Extra
+	if len(record.Raw) > 0 {
+		if exact, err := unknownFields(record.Raw, recordFields, false); err == nil {
+			extra = exact
+		}
+	}
+	return unknownFieldViolations(pointer, extra)
+}
+
+// violationsError wraps violations as a *ValidationError, or returns nil
+func violationsError(violations []Violation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: violations}
+}
+
+// DecodeError converts a syntax or type error from encoding/json into a
+// *ValidationError located under pointer, so the sender is told which value
+// to fix. Other errors are returned unchanged
+func DecodeError(err error, pointer string) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		location := pointer
+		if typeErr.Field != "" {
+			for _, name := range strings.Split(typeErr.Field, ".") {
+				location += "/" + escapePointer(name)
+			}
+		}
+		return &ValidationError{Violations: []Violation{{
+			Pointer: location,
+			Rule:    RuleType,
+			Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+		}}}
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &ValidationError{Violations: []Violation{{
+			Pointer: pointer,
+			Rule:    RuleSyntax,
+			Message: fmt.Sprintf("%v (offset %d)", syntaxErr, syntaxErr.Offset),
+		}}}
+	}
+	return err
+}
+
+// PrefixViolations moves the violations of a *ValidationError under pointer,
+// e.g. from a record's "/matchScore" to the body's "/data/matchScore"
+// Other errors are returned unchanged
+func PrefixViolations(err error, pointer string) error {
+	var validation *ValidationError
+	if pointer == "" || !errors.As(err, &validation) {
+		return err
+	}
+	prefixed := &ValidationError{Violations: make([]Violation, len(validation.Violations))}
+	for i, v := range validation.Violations {
+		v.Pointer = pointer + v.Pointer
+		prefixed.Violations[i] = v
+	}
+	return prefixed
+}
+
+// escapePointer escapes a JSON Pointer reference token (RFC 6901)
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestJSONParserStrictModeReportsEachProblem(t *testing.T) {
+	cases := map[string]struct {
+		body     string
+		pointers []string
+		rule     string
+	}{
+		"misspelled record field": {
+			body:     `{"eventType":"analytics_record_created","data":{"requestId":"r1","query":"q","timestamp":1,"matchscore":80}}`,
+			pointers: []string{"/data/matchscore"},
+			rule:     RuleUnknownField,
+		},
+		"unknown envelope and batch record fields": {
+			body:     `{"eventType":"batch","sender":"x","records":[{"requestId":"r1"},{"requestId":"r2","extra":1}]}`,
+			pointers: []string{"/sender", "/records/1/extra"},
+			rule:     RuleUnknownField,
+		},
+		"duplicate key": {
+			body:     `{"eventType":"analytics_record_created","data":{"requestId":"r1","matchScore":10,"matchScore":90}}`,
+			pointers: []string{"/data/matchScore"},
+			rule:     RuleDuplicateKey,
+		},
+		"trailing data": {
+			body:     `{"eventType":"analytics_record_created","data":{"requestId":"r1"}} {"x":1}`,
+			pointers: []string{""},
+			rule:     RuleTrailingData,
+		},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		parser := JSONParser{Mode: DecodeStrict}
+
+		// Act
+		_, err := parser.Parse([]byte(tc.body), http.Header{})
+
+		// Assert
+		var validation *ValidationError
+		if !errors.As(err, &validation) {
+			t.Fatalf("%s: expected ValidationError, got %v", name, err)
+		}
+		var pointers []string
+		for _, v := range validation.Violations {
+			if v.Rule != tc.rule {
+				t.Errorf("%s: expected rule %q, got %q", name, tc.rule, v.Rule)
+			}
+			pointers = append(pointers, v.Pointer)
+		}
+		if !reflect.DeepEqual(pointers, tc.pointers) {
+			t.Errorf("%s: expected pointers %v, got %v", name, tc.pointers, pointers)
+		}
+	}
+}
+
+func TestJSONParserPermissiveModeKeepsUnknownFields(t *testing.T) {
+	// Arrange
+	parser := JSONParser{Mode: DecodePermissive}
+	body := `{"eventType":"analytics_record_created","data":{"requestId":"r1","matchscore":80,"locale":"de"}}`
+
+	// Act
+	payload, err := parser.Parse([]byte(body), http.Header{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// matchscore is bound to MatchScore by encoding/json, so it is not extra
+	want := map[string]interface{}{"locale": "de"}
+	if !reflect.DeepEqual(payload.Data.Extra, want) || payload.Data.MatchScore != 80 {
+		t.Errorf("Expected extra %v and matchScore 80, got %v and %d", want, payload.Data.Extra, payload.Data.MatchScore)
+	}
+}
+
+func TestCloudEventsParserStrictModeAllowsExtensions(t *testing.T) {
+	// Arrange
+	parser := CloudEventsParser{Mode: DecodeStrict}
+	headers := http.Header{}
+	headers.Set(ContentTypeHeader, CloudEventsContentType)
+	body := `{"specversion":"1.0","id":"e1","source":"/bot","type":"analytics_record_created","traceparent":"00-abc","data":{"requestId":"r1","locale":"de"}}`
+
+	// Act
+	_, err := parser.Parse([]byte(body), headers)
+
+	// Assert
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if len(validation.Violations) != 1 || validation.Violations[0].Pointer != "/data/locale" {
+		t.Errorf("Expected only /data/locale to be reported, got %v", validation.Violations)
+	}
+}
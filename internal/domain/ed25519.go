@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyIDHeader names the public key the sender signed with
+	KeyIDHeader = "X-Webhook-Key-Id"
+
+	// Ed25519SignaturePrefix marks base64 Ed25519 signatures over "<timestamp>.<body>"
+	Ed25519SignaturePrefix = "ed25519="
+)
+
+// Ed25519Keyring maps key IDs to sender public keys
+// Rotation means adding the sender's new public key under a new ID
+type Ed25519Keyring map[string]ed25519.PublicKey
+
+// Ed25519Validator implements SignatureValidator using asymmetric signatures
+// The receiver holds only public keys, so it cannot forge events itself
+type Ed25519Validator struct {
+	keyring   Ed25519Keyring
+	tolerance time.Duration
+	logger    Logger
+	now       func() time.Time
+}
+
+// NewEd25519Validator creates an Ed25519 signature validator
+func NewEd25519Validator(keyring Ed25519Keyring, tolerance time.Duration, logger Logger) *Ed25519Validator {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &Ed25519Validator{keyring: keyring, tolerance: tolerance, logger: logger, now: time.Now}
+}
+
+// Validate checks the signature with the public key named by X-Webhook-Key-Id
+func (v *Ed25519Validator) Validate(payload []byte, headers Headers) error {
+	keyID := headers.Get(KeyIDHeader)
+	timestamp := headers.Get(TimestampHeader)
+
+	publicKey, ok := v.keyring[keyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown key id %q", ErrInvalidSignature, keyID)
+	}
+
+	if err := checkTimestamp(timestamp, v.now(), v.tolerance); err != nil {
+		return err
+	}
+
+	encoded, ok := strings.CutPrefix(headers.Get(SignatureHeader), Ed25519SignaturePrefix)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64", ErrInvalidSignature)
+	}
+
+	if !ed25519.Verify(publicKey, timestampedContent(payload, timestamp), signature) {
+		return ErrInvalidSignature
+	}
+
+	v.logger.Info("webhook signature matched", "scheme", "ed25519", "keyId", keyID)
+	return nil
+}
+
+// ParseEd25519PublicKeys reads public keys from a PEM file or a JWK/JWKS document
+// PEM keys carry no ID, so defaultID (usually the file name) is used; JWKs use
+// their "kid" and fall back to defaultID
+func ParseEd25519PublicKeys(defaultID string, data []byte) (Ed25519Keyring, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse PEM public key: %w", err)
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("PEM public key is %T, not Ed25519", key)
+		}
+		return Ed25519Keyring{defaultID: publicKey}, nil
+	}
+
+	return parseJWKs(defaultID, data)
+}
+
+// jwk holds the JSON Web Key fields for OKP, EC and RSA public keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	Kid string `json:"kid"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKs accepts either a single JWK or a JWKS {"keys":[...]}
+func parseJWKs(defaultID string, data []byte) (Ed25519Keyring, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("key file is neither PEM nor JWK: %w", err)
+	}
+	if len(set.Keys) == 0 {
+		var single jwk
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, fmt.Errorf("parse JWK: %w", err)
+		}
+		set.Keys = []jwk{single}
+	}
+
+	keyring := make(Ed25519Keyring, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" {
+			return nil, fmt.Errorf("JWK %q is %s/%s, not OKP/Ed25519", key.Kid, key.Kty, key.Crv)
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("JWK %q has an invalid x coordinate", key.Kid)
+		}
+
+		id := key.Kid
+		if id == "" {
+			id = defaultID
+		}
+		if _, exists := keyring[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		keyring[id] = ed25519.PublicKey(raw)
+	}
+
+	return keyring, nil
+}
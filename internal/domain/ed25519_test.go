@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func ed25519Headers(keyID, timestamp string, signature []byte) http.Header {
+	headers := http.Header{}
+	headers.Set(KeyIDHeader, keyID)
+	headers.Set(TimestampHeader, timestamp)
+	headers.Set(SignatureHeader, Ed25519SignaturePrefix+base64.StdEncoding.EncodeToString(signature))
+	return headers
+}
+
+func TestEd25519Validator(t *testing.T) {
+	// Arrange
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	_, otherPrivateKey, _ := ed25519.GenerateKey(nil)
+	validator := NewEd25519Validator(Ed25519Keyring{"lambda-2024": publicKey}, 5*time.Minute, &recordingLogger{})
+	validator.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+	content := []byte("1700000000." + string(payload))
+
+	cases := map[string]struct {
+		headers http.Header
+		want    error
+	}{
+		"valid":           {ed25519Headers("lambda-2024", "1700000000", ed25519.Sign(privateKey, content)), nil},
+		"wrong key":       {ed25519Headers("lambda-2024", "1700000000", ed25519.Sign(otherPrivateKey, content)), ErrInvalidSignature},
+		"unknown key id":  {ed25519Headers("lambda-2023", "1700000000", ed25519.Sign(privateKey, content)), ErrInvalidSignature},
+		"stale timestamp": {ed25519Headers("lambda-2024", "1699000000", ed25519.Sign(privateKey, []byte("1699000000."+string(payload)))), ErrTimestampOutOfTolerance},
+	}
+
+	for name, tc := range cases {
+		// Act
+		err := validator.Validate(payload, tc.headers)
+
+		// Assert
+		if tc.want == nil && err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestParseEd25519PublicKeysPEM(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKIXPublicKey(publicKey)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	keyring, err := ParseEd25519PublicKeys("lambda-2024", data)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !publicKey.Equal(keyring["lambda-2024"]) {
+		t.Errorf("Expected PEM key to be stored under the default ID")
+	}
+}
+
+func TestParseEd25519PublicKeysJWKS(t *testing.T) {
+	first, _, _ := ed25519.GenerateKey(nil)
+	second, _, _ := ed25519.GenerateKey(nil)
+	data := []byte(`{"keys":[` +
+		`{"kty":"OKP","crv":"Ed25519","kid":"k1","x":"` + base64.RawURLEncoding.EncodeToString(first) + `"},` +
+		`{"kty":"OKP","crv":"Ed25519","kid":"k2","x":"` + base64.RawURLEncoding.EncodeToString(second) + `"}]}`)
+
+	keyring, err := ParseEd25519PublicKeys("file", data)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keyring) != 2 || !first.Equal(keyring["k1"]) || !second.Equal(keyring["k2"]) {
+		t.Errorf("Expected keys k1 and k2, got %v", keyring)
+	}
+
+	if _, err := ParseEd25519PublicKeys("file", []byte(`{"kty":"RSA","n":"abc","e":"AQAB"}`)); err == nil {
+		t.Errorf("Expected non-Ed25519 JWK to be rejected")
+	}
+}
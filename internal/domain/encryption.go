@@ -0,0 +1,323 @@
+package domain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownKey is returned when ciphertext names a key the provider does not hold
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// encryptedPrefix marks an encrypted field value and its format version
+const encryptedPrefix = "enc:v1:"
+
+// dataKeySize is the AES-256 key size used for data and key-encryption keys
+const dataKeySize = 32
+
+// EncryptedFields are the record fields encrypted at rest. queryNormalized is
+// a copy of query, so it is encrypted with it. The strings in "extra" are
+// encrypted too, each authenticated under its JSON Pointer (e.g. "extra/note")
+var EncryptedFields = []string{"query", "reasoning", "queryNormalized"}
+
+// ExtraField is the stored name of AnalyticsRecord.Extra
+const ExtraField = "extra"
+
+// KeyProvider interface (Dependency Inversion Principle)
+// Holds the key-encryption keys; a cloud KMS can replace the local keyfile
+type KeyProvider interface {
+	// CurrentKey returns the key new data keys are wrapped with
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with id, so data written before a rotation stays readable
+	Key(id string) ([]byte, error)
+}
+
+// LocalKeyProvider implements KeyProvider with keys from a keyfile
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// keyFile is the ENCRYPTION_KEY_FILE document: base64 32-byte keys by ID
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// ParseKeyFile reads a keyfile ({"current": "k2", "keys": {"k1": "<base64>", ...}})
+// Keep retired keys in the file until everything written with them is re-encrypted
+func ParseKeyFile(document []byte) (*LocalKeyProvider, error) {
+	var file keyFile
+	if err := json.Unmarshal(document, &file); err != nil {
+		return nil, err
+	}
+
+	provider := &LocalKeyProvider{current: file.Current, keys: make(map[string][]byte, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("key id %q must be non-empty without colons", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("key %s must be %d bytes, got %d", id, dataKeySize, len(key))
+		}
+		provider.keys[id] = key
+	}
+	if _, ok := provider.keys[file.Current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in keys", file.Current)
+	}
+	return provider, nil
+}
+
+// CurrentKey implements KeyProvider
+func (p *LocalKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+// Key implements KeyProvider
+func (p *LocalKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// FieldCipher encrypts record fields with AES-GCM envelope encryption: each
+// value gets a fresh data key, which is wrapped with the provider's current key
+// An encrypted value reads "enc:v1:<keyId>:<wrapped data key>:<ciphertext>",
+// so the key ID is stored next to the ciphertext. The field name and requestId
+// are authenticated, so a value cannot be moved to another field or record
+type FieldCipher struct {
+	keys KeyProvider
+}
+
+// NewFieldCipher creates a cipher over the provider's keys
+func NewFieldCipher(keys KeyProvider) *FieldCipher {
+	return &FieldCipher{keys: keys}
+}
+
+// EncryptRecord returns record with EncryptedFields encrypted
+// Empty fields stay empty, so an update patch still only sets what it names
+func (c *FieldCipher) EncryptRecord(record AnalyticsRecord) (AnalyticsRecord, error) {
+	return c.apply(record, c.Encrypt)
+}
+
+// DecryptRecord reverses EncryptRecord, for export tooling
+// Values that are not encrypted (written before encryption was enabled) are returned as they are
+func (c *FieldCipher) DecryptRecord(record AnalyticsRecord) (AnalyticsRecord, error) {
+	return c.apply(record, c.Decrypt)
+}
+
+// DecryptDocument decrypts EncryptedFields in a stored document in place, for
+// export tooling that reads raw Firestore or Realtime Database data
+func (c *FieldCipher) DecryptDocument(document map[string]interface{}) error {
+	requestID, _ := document["requestId"].(string)
+	for _, field := range EncryptedFields {
+		value, ok := document[field].(string)
+		if !ok {
+			continue
+		}
+		plaintext, err := c.Decrypt(field, requestID, value)
+		if err != nil {
+			return err
+		}
+		document[field] = plaintext
+	}
+
+	// Firestore stores extra as a map; the Realtime Database as a JSON string
+	switch extra := document[ExtraField].(type) {
+	case map[string]interface{}:
+		decrypted, err := transformExtra(extra, requestID, c.Decrypt)
+		if err != nil {
+			return err
+		}
+		document[ExtraField] = decrypted
+	case string:
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(extra), &fields); err != nil {
+			return fmt.Errorf("%s: %w", ExtraField, err)
+		}
+		decrypted, err := transformExtra(fields, requestID, c.Decrypt)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(decrypted)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ExtraField, err)
+		}
+		document[ExtraField] = string(encoded)
+	}
+	return nil
+}
+
+// apply runs transform over the encrypted fields of record
+func (c *FieldCipher) apply(record AnalyticsRecord, transform func(field, requestID, value string) (string, error)) (AnalyticsRecord, error) {
+	fields := map[string]*string{
+		"query":           &record.Query,
+		"reasoning":       &record.Reasoning,
+		"queryNormalized": &record.QueryNormalized,
+	}
+	for _, field := range EncryptedFields {
+		value := fields[field]
+		result, err := transform(field, record.RequestID, *value)
+		if err != nil {
+			return AnalyticsRecord{}, err
+		}
+		*value = result
+	}
+
+	if len(record.Extra) > 0 {
+		extra, err := transformExtra(record.Extra, record.RequestID, transform)
+		if err != nil {
+			return AnalyticsRecord{}, err
+		}
+		record.Extra = extra
+	}
+	return record, nil
+}
+
+// transformExtra returns a copy of extra with transform applied to every
+// string, so the caller's record is left as it was
+func transformExtra(extra map[string]interface{}, requestID string, transform func(field, requestID, value string) (string, error)) (map[string]interface{}, error) {
+	var walk func(pointer string, value interface{}) (interface{}, error)
+	walk = func(pointer string, value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case string:
+			return transform(pointer, requestID, v)
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				result, err := walk(pointer+"/"+escapePointer(key), item)
+				if err != nil {
+					return nil, err
+				}
+				out[key] = result
+			}
+			return out, nil
+		case []interface{}:
+			out := make([]interface{}, len(v))
+			for i, item := range v {
+				result, err := walk(fmt.Sprintf("%s/%d", pointer, i), item)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = result
+			}
+			return out, nil
+		}
+		return value, nil
+	}
+
+	result, err := walk(ExtraField, extra)
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
+
+// Encrypt seals one field value
+func (c *FieldCipher) Encrypt(field, requestID, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	keyID, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return "", fmt.Errorf("encryption key: %w", err)
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	aad := []byte(field + "\x00" + requestID)
+	wrapped, err := seal(key, dataKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawStdEncoding
+	return encryptedPrefix + keyID + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt opens a value sealed by Encrypt for the same field and requestId
+func (c *FieldCipher) Decrypt(field, requestID, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%s: malformed encrypted value", field)
+	}
+
+	key, err := c.keys.Key(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	encoding := base64.RawStdEncoding
+	wrapped, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("%s: data key: %w", field, err)
+	}
+	ciphertext, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("%s: ciphertext: %w", field, err)
+	}
+
+	dataKey, err := open(key, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", fmt.Errorf("%s: unwrapping data key: %w", field, err)
+	}
+	plaintext, err := open(dataKey, ciphertext, []byte(field+"\x00"+requestID))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", field, err)
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts with AES-GCM and prepends the random nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open reverses seal
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// newGCM creates an AES-GCM AEAD for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testKeyFile(current string, ids ...string) []byte {
+	entries := make([]string, len(ids))
+	for i, id := range ids {
+		key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[:1], dataKeySize)))
+		entries[i] = fmt.Sprintf("%q: %q", id, key)
+	}
+	return []byte(fmt.Sprintf(`{"current": %q, "keys": {%s}}`, current, strings.Join(entries, ", ")))
+}
+
+func newTestCipher(t *testing.T, current string, ids ...string) *FieldCipher {
+	t.Helper()
+	keys, err := ParseKeyFile(testKeyFile(current, ids...))
+	if err != nil {
+		t.Fatalf("Expected keyfile to parse, got %v", err)
+	}
+	return NewFieldCipher(keys)
+}
+
+func TestFieldCipherRoundTrip(t *testing.T) {
+	// Arrange
+	cipher := newTestCipher(t, "a1", "a1")
+	record := AnalyticsRecord{RequestID: "r1", Query: "Go jobs?", QueryNormalized: "go jobs?", MatchType: "full"}
+
+	// Act
+	encrypted, err := cipher.EncryptRecord(record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	decrypted, err := cipher.DecryptRecord(encrypted)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(encrypted.Query, "enc:v1:a1:") || strings.Contains(encrypted.QueryNormalized, "go jobs") {
+		t.Errorf("Expected ciphertext with key id, got %q and %q", encrypted.Query, encrypted.QueryNormalized)
+	}
+	if encrypted.Reasoning != "" || encrypted.MatchType != "full" {
+		t.Errorf("Expected empty and unencrypted fields unchanged, got %+v", encrypted)
+	}
+	if decrypted.Query != record.Query || decrypted.QueryNormalized != record.QueryNormalized {
+		t.Errorf("Expected the original text back, got %+v", decrypted)
+	}
+}
+
+func TestFieldCipherDecryptsAfterRotation(t *testing.T) {
+	// Arrange: written with a1, read after b2 became current
+	before := newTestCipher(t, "a1", "a1")
+	after := newTestCipher(t, "b2", "a1", "b2")
+	retired := newTestCipher(t, "b2", "b2")
+	value, err := before.Encrypt("query", "r1", "hello")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Act
+	plaintext, err := after.Decrypt("query", "r1", value)
+	_, retiredErr := retired.Decrypt("query", "r1", value)
+
+	// Assert
+	if err != nil || plaintext != "hello" {
+		t.Errorf("Expected hello, got %q (%v)", plaintext, err)
+	}
+	if !errors.Is(retiredErr, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey once a1 is removed, got %v", retiredErr)
+	}
+}
+
+func TestFieldCipherRejectsMovedCiphertext(t *testing.T) {
+	// Arrange
+	cipher := newTestCipher(t, "a1", "a1")
+	value, _ := cipher.Encrypt("query", "r1", "hello")
+
+	// Act
+	_, otherRecord := cipher.Decrypt("query", "r2", value)
+	_, otherField := cipher.Decrypt("reasoning", "r1", value)
+
+	// Assert
+	if otherRecord == nil || otherField == nil {
+		t.Errorf("Expected ciphertext bound to its field and record, got %v and %v", otherRecord, otherField)
+	}
+}
+
+func TestFieldCipherDecryptDocument(t *testing.T) {
+	// Arrange
+	cipher := newTestCipher(t, "a1", "a1")
+	query, _ := cipher.Encrypt("query", "r1", "hello")
+	document := map[string]interface{}{"requestId": "r1", "query": query, "reasoning": "stored before encryption", "matchScore": 80}
+
+	// Act
+	err := cipher.DecryptDocument(document)
+
+	// Assert
+	if err != nil || document["query"] != "hello" || document["reasoning"] != "stored before encryption" {
+		t.Errorf("Expected decrypted document, got %v (%v)", document, err)
+	}
+}
+
+func TestFieldCipherEncryptsExtraFields(t *testing.T) {
+	// Arrange
+	cipher := newTestCipher(t, "a1", "a1")
+	record := AnalyticsRecord{RequestID: "r1", Extra: map[string]interface{}{
+		"note": "hello", "tags": []interface{}{"private"}, "count": float64(3),
+	}}
+
+	// Act
+	encrypted, err := cipher.EncryptRecord(record)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	encoded, _ := json.Marshal(encrypted.Extra)
+	document := map[string]interface{}{"requestId": "r1", "extra": string(encoded)}
+	decryptErr := cipher.DecryptDocument(document)
+	_, movedErr := cipher.Decrypt("extra/other", "r1", encrypted.Extra["note"].(string))
+
+	// Assert
+	note, _ := encrypted.Extra["note"].(string)
+	if !strings.HasPrefix(note, "enc:v1:a1:") || strings.Contains(string(encoded), "private") || encrypted.Extra["count"] != float64(3) {
+		t.Errorf("Expected extra strings encrypted and numbers kept, got %v", encrypted.Extra)
+	}
+	if record.Extra["note"] != "hello" {
+		t.Errorf("Expected the original record unchanged, got %v", record.Extra)
+	}
+	if decryptErr != nil || document["extra"] != `{"count":3,"note":"hello","tags":["private"]}` {
+		t.Errorf("Expected the stored extra decrypted, got %v (%v)", document["extra"], decryptErr)
+	}
+	if movedErr == nil {
+		t.Errorf("Expected ciphertext bound to its extra field")
+	}
+}
+
+func TestParseKeyFileRejectsBadKeys(t *testing.T) {
+	cases := map[string]string{
+		"current key missing": string(testKeyFile("b2", "a1")),
+		"short key":           `{"current": "a1", "keys": {"a1": "c2hvcnQ="}}`,
+		"colon in id":         `{"current": "a:1", "keys": {"a:1": "` + base64.StdEncoding.EncodeToString(make([]byte, dataKeySize)) + `"}}`,
+	}
+
+	for name, document := range cases {
+		if _, err := ParseKeyFile([]byte(document)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Enricher interface (Dependency Inversion Principle)
+// Derives fields from a validated record before it is written
+type Enricher interface {
+	Enrich(record AnalyticsRecord) AnalyticsRecord
+}
+
+// Enricher names accepted in ENRICHERS and a tenant's "enrichers"
+const (
+	EnricherWeek           = "week"
+	EnricherNormalizeQuery = "normalizeQuery"
+	EnricherScoreBand      = "scoreBand"
+	EnricherReceivedAt     = "receivedAt"
+)
+
+// DefaultEnricherNames is the chain used when none is configured
+var DefaultEnricherNames = []string{EnricherWeek, EnricherNormalizeQuery, EnricherScoreBand, EnricherReceivedAt}
+
+// Enrichers runs enrichers in order, each seeing the previous one's output
+type Enrichers []Enricher
+
+// Enrich implements Enricher
+func (e Enrichers) Enrich(record AnalyticsRecord) AnalyticsRecord {
+	for _, enricher := range e {
+		record = enricher.Enrich(record)
+	}
+	return record
+}
+
+// EnrichRecord runs enricher on a validated record. If it changed a field the
+// sender set, such as a query trimmed to nothing, the record is checked again
+// with validate, after Raw is updated to match
+func EnrichRecord(record AnalyticsRecord, enricher Enricher, validate func(*AnalyticsRecord) error) (AnalyticsRecord, error) {
+	enriched := enricher.Enrich(record)
+	changed := changedFields(record, enriched)
+	if len(changed) == 0 {
+		return enriched, nil
+	}
+	enriched.Raw = correctRaw(&enriched, changed)
+	return enriched, validate(&enriched)
+}
+
+// RuleNormalizedEmpty is reported for an update field an enricher emptied
+const RuleNormalizedEmpty = "normalizedEmpty"
+
+// EnrichPatch is EnrichRecord for an update. A field the patch set that an
+// enricher empties would drop out of the update unnoticed, so it is reported
+func EnrichPatch(patch AnalyticsRecord, enricher Enricher, validate func(*AnalyticsRecord) error) (AnalyticsRecord, error) {
+	enriched, err := EnrichRecord(patch, enricher, validate)
+	if err != nil {
+		return enriched, err
+	}
+	set := PatchFields(enriched)
+	var violations []Violation
+	for name := range PatchFields(patch) {
+		if _, ok := set[name]; !ok {
+			violations = append(violations, Violation{Pointer: "/" + name, Rule: RuleNormalizedEmpty, Message: "is empty once normalized"})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].Pointer < violations[j].Pointer })
+	return enriched, violationsError(violations)
+}
+
+// changedFields lists the JSON fields set in before (not zero, as in
+// PatchFields) whose values differ in after. Fields an enricher fills in,
+// such as a missing week, are not listed
+func changedFields(before, after AnalyticsRecord) map[string]bool {
+	old, err := jsonFields(before)
+	if err != nil {
+		return nil
+	}
+	current, err := jsonFields(after)
+	if err != nil {
+		return nil
+	}
+	changed := make(map[string]bool)
+	for field, value := range current {
+		if zeroJSON(old[field]) {
+			continue
+		}
+		if !bytes.Equal(old[field], value) {
+			changed[field] = true
+		}
+	}
+	return changed
+}
+
+// zeroJSON reports whether value is an encoded zero value
+func zeroJSON(value json.RawMessage) bool {
+	switch string(value) {
+	case "", `""`, "0", "null":
+		return true
+	}
+	return false
+}
+
+// jsonFields encodes record and splits it into its top-level fields
+func jsonFields(record AnalyticsRecord) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(encoded, &fields)
+	return fields, err
+}
+
+// NewEnrichers builds a chain from enricher names, in the order given
+func NewEnrichers(names []string) (Enrichers, error) {
+	enrichers := make(Enrichers, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case EnricherWeek:
+			enrichers = append(enrichers, WeekEnricher{})
+		case EnricherNormalizeQuery:
+			enrichers = append(enrichers, QueryNormalizer{})
+		case EnricherScoreBand:
+			enrichers = append(enrichers, ScoreBandEnricher{Bands: DefaultScoreBands})
+		case EnricherReceivedAt:
+			enrichers = append(enrichers, ReceivedAtEnricher{Now: time.Now})
+		default:
+			return nil, fmt.Errorf("unknown enricher %q", name)
+		}
+	}
+	return enrichers, nil
+}
+
+// WeekEnricher derives a missing week from the timestamp, as the ISO week in UTC
+type WeekEnricher struct{}
+
+// Enrich implements Enricher
+func (WeekEnricher) Enrich(record AnalyticsRecord) AnalyticsRecord {
+	if record.Week == "" && record.Timestamp > 0 {
+		record.Week = isoWeek(record.Timestamp)
+	}
+	return record
+}
+
+// QueryNormalizer trims the query and converts it to Unicode NFC, so the same
+// text typed on different keyboards is stored the same way, and adds a
+// lowercased copy for case-insensitive grouping
+type QueryNormalizer struct{}
+
+// Enrich implements Enricher
+func (QueryNormalizer) Enrich(record AnalyticsRecord) AnalyticsRecord {
+	record.Query = norm.NFC.String(strings.TrimSpace(record.Query))
+	record.QueryNormalized = strings.ToLower(record.Query)
+	return record
+}
+
+// ScoreBand names the scores from Min upwards, up to the next band
+type ScoreBand struct {
+	Name string
+	Min  int
+}
+
+// DefaultScoreBands split matchScore (0-100) into three bands
+var DefaultScoreBands = []ScoreBand{
+	{Name: "high", Min: 80},
+	{Name: "medium", Min: 50},
+	{Name: "low", Min: 0},
+}
+
+// ScoreBandEnricher sets ScoreBand from matchScore
+// Bands are ordered from the highest Min down; a score below every band gets none
+type ScoreBandEnricher struct {
+	Bands []ScoreBand
+}
+
+// Enrich implements Enricher
+func (e ScoreBandEnricher) Enrich(record AnalyticsRecord) AnalyticsRecord {
+	for _, band := range e.Bands {
+		if record.MatchScore >= band.Min {
+			record.ScoreBand = band.Name
+			break
+		}
+	}
+	return record
+}
+
+// ReceivedAtEnricher stamps the time the receiver accepted the record
+type ReceivedAtEnricher struct {
+	Now func() time.Time
+}
+
+// Enrich implements Enricher
+func (e ReceivedAtEnricher) Enrich(record AnalyticsRecord) AnalyticsRecord {
+	now := e.Now()
+	record.ReceivedAt = now.Unix()
+	record.ReceivedAtMillis = now.UnixMilli()
+	return record
+}
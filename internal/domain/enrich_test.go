@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEnrichers(t *testing.T) {
+	received := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		enricher Enricher
+		record   AnalyticsRecord
+		check    func(AnalyticsRecord) bool
+	}{
+		"week is derived when missing": {
+			enricher: WeekEnricher{},
+			record:   AnalyticsRecord{Timestamp: 1698765432000},
+			check:    func(r AnalyticsRecord) bool { return r.Week == "2023-W44" },
+		},
+		"week set by the sender is kept": {
+			enricher: WeekEnricher{},
+			record:   AnalyticsRecord{Timestamp: 1698765432000, Week: "2023-W40"},
+			check:    func(r AnalyticsRecord) bool { return r.Week == "2023-W40" },
+		},
+		"query is trimmed, composed and lowercased": {
+			enricher: QueryNormalizer{},
+			record:   AnalyticsRecord{Query: "  Cafe\u0301 GO? "}, // e + combining acute
+			check: func(r AnalyticsRecord) bool {
+				return r.Query == "Caf\u00e9 GO?" && r.QueryNormalized == "caf\u00e9 go?"
+			},
+		},
+		"score falls in the band at or above its minimum": {
+			enricher: ScoreBandEnricher{Bands: DefaultScoreBands},
+			record:   AnalyticsRecord{MatchScore: 50},
+			check:    func(r AnalyticsRecord) bool { return r.ScoreBand == "medium" },
+		},
+		"receive time is stamped in both units": {
+			enricher: ReceivedAtEnricher{Now: func() time.Time { return received }},
+			record:   AnalyticsRecord{},
+			check: func(r AnalyticsRecord) bool {
+				return r.ReceivedAt == received.Unix() && r.ReceivedAtMillis == received.UnixMilli()
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		// Act
+		got := tc.enricher.Enrich(tc.record)
+
+		// Assert
+		if !tc.check(got) {
+			t.Errorf("%s: unexpected record %+v", name, got)
+		}
+	}
+}
+
+func TestNewEnrichersRunsInOrder(t *testing.T) {
+	// Arrange
+	enrichers, err := NewEnrichers([]string{EnricherWeek, EnricherScoreBand})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Act
+	got := enrichers.Enrich(AnalyticsRecord{Timestamp: 1698765432000, MatchScore: 95})
+
+	// Assert
+	if got.Week != "2023-W44" || got.ScoreBand != "high" || got.QueryNormalized != "" {
+		t.Errorf("Expected only week and band to be set, got %+v", got)
+	}
+	if _, err := NewEnrichers([]string{"geoip"}); err == nil {
+		t.Error("Expected an unknown enricher to be rejected")
+	}
+}
+
+func TestEnrichRecordRechecksChangedFields(t *testing.T) {
+	// Arrange
+	enrichers, _ := NewEnrichers([]string{EnricherWeek, EnricherNormalizeQuery})
+	var checked []string
+	validate := func(record *AnalyticsRecord) error {
+		checked = append(checked, string(record.Raw))
+		return nil
+	}
+	derived := AnalyticsRecord{RequestID: "r1", Query: "Go?", Timestamp: 1698765432000}
+	trimmed := AnalyticsRecord{RequestID: "r2", Query: "  Go?  ", Timestamp: 1698765432000, Raw: json.RawMessage(`{"query":"  Go?  "}`)}
+
+	// Act
+	first, _ := EnrichRecord(derived, enrichers, validate)
+	second, _ := EnrichRecord(trimmed, enrichers, validate)
+
+	// Assert
+	if first.Week != "2023-W44" || second.Query != "Go?" {
+		t.Fatalf("Expected both records to be enriched, got %+v and %+v", first, second)
+	}
+	if len(checked) != 1 || checked[0] != `{"query":"Go?"}` {
+		t.Errorf("Expected only the trimmed record to be checked again, against its updated Raw, got %q", checked)
+	}
+}
@@ -7,12 +7,48 @@ var (
 	// ErrInvalidSignature returned when HMAC signature validation fails
 	ErrInvalidSignature = errors.New("invalid webhook signature")
 
-	// ErrDatabaseWrite returned when Firebase write fails
+	// ErrInvalidToken returned when bearer token verification fails
+	ErrInvalidToken = errors.New("invalid bearer token")
+
+	// ErrMissingTimestamp returned when a timestamped signature has no usable timestamp header
+	ErrMissingTimestamp = errors.New("missing or malformed webhook timestamp")
+
+	// ErrTimestampOutOfTolerance returned when the sender timestamp is stale or future-dated
+	ErrTimestampOutOfTolerance = errors.New("webhook timestamp outside tolerance window")
+
+	// ErrDuplicateDelivery returned when a message ID has already been processed
+	ErrDuplicateDelivery = errors.New("duplicate webhook delivery")
+
+	// ErrDatabaseWrite returned when a storage sink or the nonce store fails
 	ErrDatabaseWrite = errors.New("failed to write to database")
 
 	// ErrInvalidPayload returned when webhook payload validation fails
 	ErrInvalidPayload = errors.New("invalid webhook payload")
 
+	// ErrBatchTooLarge returned when a batch exceeds MaxBatchRecords
+	ErrBatchTooLarge = errors.New("batch exceeds maximum record count")
+
+	// ErrUnsupportedEncoding returned when Content-Encoding names an unknown coding
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+	// ErrMalformedEncoding returned when a compressed body cannot be decoded
+	ErrMalformedEncoding = errors.New("malformed compressed body")
+
+	// ErrDecodedTooLarge returned when a compressed body expands past the configured cap
+	ErrDecodedTooLarge = errors.New("decompressed body too large")
+
+	// ErrUnknownEventType returned when no handler is registered for a payload's eventType
+	ErrUnknownEventType = errors.New("unknown event type")
+
+	// ErrRecordNotFound returned when an update or delete targets a record that is not stored
+	ErrRecordNotFound = errors.New("analytics record not found")
+
+	// ErrUnsupportedSchemaVersion returned when a payload's schemaVersion is newer than the receiver or has no upcaster
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+
 	// ErrMissingField returned when required field is missing
 	ErrMissingField = errors.New("missing required field")
+
+	// ErrRetryQueueFull returned when a failed best-effort write cannot be queued for retry
+	ErrRetryQueueFull = errors.New("retry queue full")
 )
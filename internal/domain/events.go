@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Analytics event types sent by the chatbot Lambda
+const (
+	EventTypeCreated = "analytics_record_created"
+	EventTypeUpdated = "analytics_record_updated"
+	EventTypeDeleted = "analytics_record_deleted"
+)
+
+// EventHandler applies one kind of event to storage
+type EventHandler interface {
+	Handle(ctx context.Context, payload *WebhookPayload) (*ProcessResult, error)
+}
+
+// EventHandlerFunc adapts a function to EventHandler
+type EventHandlerFunc func(ctx context.Context, payload *WebhookPayload) (*ProcessResult, error)
+
+// Handle implements EventHandler
+func (f EventHandlerFunc) Handle(ctx context.Context, payload *WebhookPayload) (*ProcessResult, error) {
+	return f(ctx, payload)
+}
+
+// EventRouter dispatches payloads by EventType (Open/Closed Principle)
+// New event types are supported by registering a handler, not by editing the service
+type EventRouter struct {
+	handlers map[string]EventHandler
+}
+
+// NewEventRouter creates an empty router
+func NewEventRouter() *EventRouter {
+	return &EventRouter{handlers: make(map[string]EventHandler)}
+}
+
+// Register adds or replaces the handler for an event type
+func (r *EventRouter) Register(eventType string, handler EventHandler) {
+	r.handlers[eventType] = handler
+}
+
+// Handle implements EventHandler by dispatching to the registered handler
+func (r *EventRouter) Handle(ctx context.Context, payload *WebhookPayload) (*ProcessResult, error) {
+	handler, ok := r.handlers[payload.EventType]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (supported: %v)", ErrUnknownEventType, payload.EventType, r.Types())
+	}
+	return handler.Handle(ctx, payload)
+}
+
+// Types returns the registered event types in sorted order
+func (r *EventRouter) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for eventType := range r.handlers {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// RecordUpdater is implemented by writers that can change stored records
+type RecordUpdater interface {
+	// Update merges the fields set in patch into the record with patch.RequestID
+	Update(ctx context.Context, patch AnalyticsRecord) error
+}
+
+// RecordDeleter is implemented by writers that can remove stored records
+type RecordDeleter interface {
+	Delete(ctx context.Context, requestID string) error
+	// Tombstone marks the record deleted but keeps it for reporting
+	Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error
+}
+
+// PatchFields lists the fields set in patch by their stored names
+// Zero values mean "unchanged", matching proto3 where unset and zero are the same
+func PatchFields(patch AnalyticsRecord) map[string]interface{} {
+	fields := make(map[string]interface{})
+	set := func(name string, value interface{}, isSet bool) {
+		if isSet {
+			fields[name] = value
+		}
+	}
+	set("query", patch.Query, patch.Query != "")
+	set("matchType", patch.MatchType, patch.MatchType != "")
+	set("matchScore", patch.MatchScore, patch.MatchScore != 0)
+	set("reasoning", patch.Reasoning, patch.Reasoning != "")
+	set("vectorMatches", patch.VectorMatches, patch.VectorMatches != 0)
+	set("sessionId", patch.SessionID, patch.SessionID != "")
+	set("week", patch.Week, patch.Week != "")
+	set("timestamp", patch.Timestamp, patch.Timestamp != 0)
+	set("redactions", patch.Redactions, len(patch.Redactions) > 0)
+	// Derived by the enrichers, so they follow the fields they come from
+	set("queryNormalized", patch.QueryNormalized, patch.Query != "" && patch.QueryNormalized != "")
+	set("scoreBand", patch.ScoreBand, patch.MatchScore != 0 && patch.ScoreBand != "")
+	return fields
+}
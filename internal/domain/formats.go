@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// ContentTypeHeader selects the payload format
+const ContentTypeHeader = "Content-Type"
+
+// PayloadParser interface (Dependency Inversion Principle)
+// Maps one wire format onto WebhookPayload
+type PayloadParser interface {
+	Parse(body []byte, headers Headers) (*WebhookPayload, error)
+}
+
+// JSONParser parses the receiver's own JSON envelope, upcasting records
+// sent in older schema versions. Mode decides what happens to unknown fields
+type JSONParser struct {
+	Schemas *SchemaVersions
+	Mode    DecodeMode
+}
+
+// jsonEnvelope defers record decoding until the schema version is known
+type jsonEnvelope struct {
+	EventType     string            `json:"eventType"`
+	Timestamp     int64             `json:"timestamp"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Data          json.RawMessage   `json:"data"`
+	Records       []json.RawMessage `json:"records"`
+}
+
+// Parse implements PayloadParser
+func (p JSONParser) Parse(body []byte, headers Headers) (*WebhookPayload, error) {
+	if p.Mode == DecodeStrict {
+		if err := CheckStrictJSON(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, DecodeError(err, "")
+	}
+	version, err := p.Schemas.Check(envelope.SchemaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strict mode collects every unknown field before failing, so the sender
+	// can fix them all at once
+	violations, err := StrictEnvelopeViolations(p.Mode, body)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &WebhookPayload{EventType: envelope.EventType, Timestamp: envelope.Timestamp, SchemaVersion: version}
+	if len(envelope.Data) > 0 && string(envelope.Data) != "null" {
+		if payload.Data, err = p.Schemas.DecodeRecord(envelope.Data, version); err != nil {
+			return nil, PrefixViolations(err, "/data")
+		}
+		violations = append(violations, StrictRecordViolations(p.Mode, "/data", payload.Data)...)
+	}
+	for i, raw := range envelope.Records {
+		record, err := p.Schemas.DecodeRecord(raw, version)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, PrefixViolations(err, fmt.Sprintf("/records/%d", i)))
+		}
+		violations = append(violations, StrictRecordViolations(p.Mode, fmt.Sprintf("/records/%d", i), record)...)
+		payload.Records = append(payload.Records, record)
+	}
+
+	if err := violationsError(violations); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// headerRule picks a parser when a header is present, whatever the media type
+type headerRule struct {
+	header string
+	parser PayloadParser
+}
+
+// PayloadFormats implements PayloadParser by content negotiation (Open/Closed Principle)
+// New formats are supported by registering a parser, not by editing the service
+type PayloadFormats struct {
+	headerRules []headerRule
+	byMediaType map[string]PayloadParser
+	fallback    PayloadParser
+}
+
+// NewPayloadFormats creates a negotiator that uses fallback for unregistered media types
+func NewPayloadFormats(fallback PayloadParser) *PayloadFormats {
+	return &PayloadFormats{byMediaType: make(map[string]PayloadParser), fallback: fallback}
+}
+
+// NewDefaultPayloadFormats registers the JSON envelope, protobuf and CloudEvents,
+// each upcasting with NewDefaultSchemaVersions and decoding in mode
+func NewDefaultPayloadFormats(mode DecodeMode) *PayloadFormats {
+	schemas := NewDefaultSchemaVersions()
+	f := NewPayloadFormats(JSONParser{Schemas: schemas, Mode: mode})
+	protobuf := ProtobufParser{Schemas: schemas, Mode: mode}
+	f.Register(ProtobufContentType, protobuf)
+	f.Register(ProtobufAltContentType, protobuf)
+	cloudEvents := CloudEventsParser{Schemas: schemas, Mode: mode}
+	f.RegisterHeader(CloudEventsSpecVersionHeader, cloudEvents)
+	f.Register(CloudEventsContentType, cloudEvents)
+	f.Register(CloudEventsBatchContentType, cloudEvents)
+	return f
+}
+
+// Register adds or replaces the parser for a media type
+func (f *PayloadFormats) Register(mediaType string, parser PayloadParser) {
+	f.byMediaType[strings.ToLower(mediaType)] = parser
+}
+
+// RegisterHeader selects parser whenever header is present; header rules are
+// checked in registration order, before the media type
+func (f *PayloadFormats) RegisterHeader(header string, parser PayloadParser) {
+	f.headerRules = append(f.headerRules, headerRule{header: header, parser: parser})
+}
+
+// Parse implements PayloadParser
+func (f *PayloadFormats) Parse(body []byte, headers Headers) (*WebhookPayload, error) {
+	for _, rule := range f.headerRules {
+		if headers.Get(rule.header) != "" {
+			return rule.parser.Parse(body, headers)
+		}
+	}
+
+	if contentType := headers.Get(ContentTypeHeader); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed Content-Type: %v", ErrInvalidPayload, err)
+		}
+		if parser, ok := f.byMediaType[mediaType]; ok {
+			return parser.Parse(body, headers)
+		}
+	}
+
+	return f.fallback.Parse(body, headers)
+}
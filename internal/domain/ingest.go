@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"io"
+)
+
+// MaxReportedLineErrors caps how many rejected lines are listed in a summary;
+// the Rejected count still covers every line
+const MaxReportedLineErrors = 1000
+
+// LineError describes one NDJSON line that was not stored
+type LineError struct {
+	Line       int         `json:"line"`
+	RequestID  string      `json:"requestId,omitempty"`
+	Reason     string      `json:"reason"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// IngestSummary reports the outcome of a streamed bulk load
+type IngestSummary struct {
+	Lines     int         `json:"lines"`
+	Accepted  int         `json:"accepted"`
+	Duplicate int         `json:"duplicate"`
+	Rejected  int         `json:"rejected"`
+	Errors    []LineError `json:"errors,omitempty"`
+}
+
+// Reject counts a rejected line, listing it while under MaxReportedLineErrors
+func (s *IngestSummary) Reject(line int, requestID string, err error) {
+	s.Rejected++
+	if len(s.Errors) < MaxReportedLineErrors {
+		reason, violations := Rejection(err)
+		s.Errors = append(s.Errors, LineError{Line: line, RequestID: requestID, Reason: reason, Violations: violations})
+	}
+}
+
+// StreamIngester interface (Dependency Inversion Principle)
+// Loads newline-delimited records from a stream without buffering the whole body
+type StreamIngester interface {
+	// Ingest returns the summary so far alongside any error that stopped the stream
+	Ingest(ctx context.Context, body io.Reader, headers Headers) (*IngestSummary, error)
+}
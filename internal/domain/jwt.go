@@ -0,0 +1,196 @@
+package domain
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// AuthorizationHeader carries the "Bearer <jwt>" identity token
+const AuthorizationHeader = "Authorization"
+
+// jwtMethods are the asymmetric algorithms accepted; HMAC and "none" are
+// never allowed so a public key cannot be used as a shared secret
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// JWTPolicy lists the claims a bearer token must carry
+type JWTPolicy struct {
+	Issuer         string
+	Audience       string
+	RequiredClaims map[string]string // claim name -> required value
+	Leeway         time.Duration     // allowed clock skew for exp and nbf
+}
+
+// JWTValidator implements SignatureValidator by verifying an OIDC/JWT bearer token
+// against a JWKS. The token authenticates the sender; it does not sign the body
+type JWTValidator struct {
+	keys   map[string]crypto.PublicKey
+	policy JWTPolicy
+	logger Logger
+	now    func() time.Time
+}
+
+// NewJWTValidator creates a bearer-token validator
+func NewJWTValidator(keys map[string]crypto.PublicKey, policy JWTPolicy, logger Logger) (*JWTValidator, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable keys")
+	}
+	if policy.Issuer == "" || policy.Audience == "" {
+		return nil, errors.New("JWT issuer and audience are required")
+	}
+	return &JWTValidator{keys: keys, policy: policy, logger: logger, now: time.Now}, nil
+}
+
+// Validate verifies the token signature and enforces issuer, audience, expiry
+// and the configured claim requirements
+func (v *JWTValidator) Validate(payload []byte, headers Headers) error {
+	raw, ok := strings.CutPrefix(headers.Get(AuthorizationHeader), "Bearer ")
+	if !ok || raw == "" {
+		return fmt.Errorf("%w: missing bearer token", ErrInvalidToken)
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(jwtMethods), jwt.WithoutClaimsValidation())
+	token, err := parser.ParseWithClaims(raw, claims, v.keyFor)
+	if err != nil || !token.Valid {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return err
+	}
+
+	v.logger.Info("bearer token verified", "scheme", "jwt", "keyId", token.Header["kid"], "subject", claims["sub"])
+	return nil
+}
+
+// keyFor selects the JWKS key named by the token's "kid"
+// A token without "kid" is accepted only when the JWKS has a single key
+func (v *JWTValidator) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// checkClaims enforces the policy using the validator clock
+func (v *JWTValidator) checkClaims(claims jwt.MapClaims) error {
+	now := v.now()
+
+	if !claims.VerifyExpiresAt(now.Add(-v.policy.Leeway).Unix(), true) {
+		return fmt.Errorf("%w: token expired or has no exp", ErrInvalidToken)
+	}
+	if !claims.VerifyNotBefore(now.Add(v.policy.Leeway).Unix(), false) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	if !claims.VerifyIssuer(v.policy.Issuer, true) {
+		return fmt.Errorf("%w: unexpected issuer %v", ErrInvalidToken, claims["iss"])
+	}
+	if !claims.VerifyAudience(v.policy.Audience, true) {
+		return fmt.Errorf("%w: audience does not include %q", ErrInvalidToken, v.policy.Audience)
+	}
+
+	for name, want := range v.policy.RequiredClaims {
+		got, ok := claims[name]
+		if !ok || fmt.Sprint(got) != want {
+			return fmt.Errorf("%w: claim %q must be %q", ErrInvalidToken, name, want)
+		}
+	}
+
+	return nil
+}
+
+// ParseJWKS reads RSA, EC and Ed25519 public keys from a JWKS document
+// Keys are indexed by "kid"; a set with a single key may omit it
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWK %q: %w", key.Kid, err)
+		}
+		if key.Kid == "" && len(set.Keys) > 1 {
+			return nil, errors.New("JWKS with several keys must set kid on each")
+		}
+		if _, exists := keys[key.Kid]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.Kid)
+		}
+		keys[key.Kid] = publicKey
+	}
+
+	return keys, nil
+}
+
+// publicKey decodes the JWK into a crypto public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, errN := decode(k.N)
+		e, errE := decode(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 {
+			return nil, errors.New("invalid RSA modulus or exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, errX := decode(k.X)
+		y, errY := decode(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC coordinates")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+
+	case "OKP":
+		x, err := decode(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
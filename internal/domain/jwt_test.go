@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func bearerHeaders(token string) http.Header {
+	headers := http.Header{}
+	headers.Set(AuthorizationHeader, "Bearer "+token)
+	return headers
+}
+
+func TestJWTValidator(t *testing.T) {
+	// Arrange: a JWKS holding one EC key, as a sender's identity provider would publish
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := []byte(`{"keys":[{"kty":"EC","crv":"P-256","kid":"idp-1",` +
+		`"x":"` + base64.RawURLEncoding.EncodeToString(privateKey.X.FillBytes(make([]byte, 32))) + `",` +
+		`"y":"` + base64.RawURLEncoding.EncodeToString(privateKey.Y.FillBytes(make([]byte, 32))) + `"}]}`)
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatalf("Expected JWKS to parse, got %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	validator, err := NewJWTValidator(keys, JWTPolicy{
+		Issuer:         "https://accounts.google.com",
+		Audience:       "https://webhook.example.com",
+		RequiredClaims: map[string]string{"email": "lambda@cv-analytics.iam.gserviceaccount.com"},
+	}, &recordingLogger{})
+	if err != nil {
+		t.Fatalf("Expected validator, got %v", err)
+	}
+	validator.now = func() time.Time { return now }
+
+	claims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":   "https://accounts.google.com",
+			"aud":   "https://webhook.example.com",
+			"sub":   "1234567890",
+			"email": "lambda@cv-analytics.iam.gserviceaccount.com",
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+	signES256 := func(c jwt.MapClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+		token.Header["kid"] = kid
+		signed, _ := token.SignedString(privateKey)
+		return signed
+	}
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString([]byte("guess"))
+
+	cases := map[string]struct {
+		token string
+		valid bool
+	}{
+		"valid":               {signES256(claims(nil), "idp-1"), true},
+		"wrong audience":      {signES256(claims(jwt.MapClaims{"aud": "https://other.example.com"}), "idp-1"), false},
+		"wrong issuer":        {signES256(claims(jwt.MapClaims{"iss": "https://evil.example.com"}), "idp-1"), false},
+		"expired":             {signES256(claims(jwt.MapClaims{"exp": now.Add(-time.Hour).Unix()}), "idp-1"), false},
+		"missing claim value": {signES256(claims(jwt.MapClaims{"email": "someone@example.com"}), "idp-1"), false},
+		"unknown key id":      {signES256(claims(nil), "idp-2"), false},
+		"symmetric algorithm": {hs256, false},
+	}
+
+	for name, tc := range cases {
+		// Act
+		err := validator.Validate([]byte(`{}`), bearerHeaders(tc.token))
+
+		// Assert
+		if tc.valid && err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+		if !tc.valid && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTValidatorRequiresPolicy(t *testing.T) {
+	_, err := NewJWTValidator(nil, JWTPolicy{Issuer: "iss", Audience: "aud"}, &recordingLogger{})
+	if err == nil {
+		t.Errorf("Expected error for empty JWKS, got nil")
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// MessageIdentifier is implemented by validators whose signature covers a
+// sender-assigned delivery ID, such as Standard Webhooks' webhook-id
+type MessageIdentifier interface {
+	// MessageID returns the authenticated delivery ID, or "" if there is none
+	MessageID(headers Headers) string
+}
+
+// MessageID returns the delivery ID used for duplicate detection
+// Only an ID the validator authenticated is used; any other header, such as
+// a binary-mode CloudEvent's ce-id, can be changed on a replay, so otherwise
+// the ID is a digest of the signed body in every mode
+func MessageID(payload []byte, headers Headers, validator SignatureValidator) string {
+	if identifier, ok := validator.(MessageIdentifier); ok {
+		if id := identifier.MessageID(headers); id != "" {
+			return id
+		}
+	}
+
+	sum := sha256.Sum256(payload)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
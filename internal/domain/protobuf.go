@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+
+	"example.com/webhook-receiver/internal/analyticspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Media types accepted for the protobuf wire format (internal/analyticspb/analytics.proto)
+const (
+	ProtobufContentType    = "application/x-protobuf"
+	ProtobufAltContentType = "application/protobuf"
+)
+
+// ProtobufParser parses analyticspb.WebhookPayload messages
+// Protobuf schemas evolve by adding fields, so older messages decode into the
+// current record as they are; only the arrival version is checked and recorded
+// In strict Mode, fields this build does not know are rejected
+type ProtobufParser struct {
+	Schemas *SchemaVersions
+	Mode    DecodeMode
+}
+
+// Parse implements PayloadParser
+func (p ProtobufParser) Parse(body []byte, headers Headers) (*WebhookPayload, error) {
+	var message analyticspb.WebhookPayload
+	// A malformed message is reported like malformed JSON, as a 422
+	if err := proto.Unmarshal(body, &message); err != nil {
+		return nil, &ValidationError{Violations: []Violation{{Pointer: "", Rule: RuleSyntax, Message: err.Error()}}}
+	}
+	version, err := p.Schemas.Check(int(message.GetSchemaVersion()))
+	if err != nil {
+		return nil, err
+	}
+	if p.Mode == DecodeStrict {
+		if err := violationsError(unknownProtoFields(&message)); err != nil {
+			return nil, err
+		}
+	}
+
+	payload := &WebhookPayload{
+		EventType:     message.GetEventType(),
+		Timestamp:     message.GetTimestamp(),
+		SchemaVersion: version,
+		Data:          recordFromProto(message.GetData(), version),
+	}
+	for _, record := range message.GetRecords() {
+		payload.Records = append(payload.Records, recordFromProto(record, version))
+	}
+	return payload, nil
+}
+
+// unknownProtoFields reports messages carrying fields missing from
+// analytics.proto, which proto.Unmarshal keeps as unknown bytes. Unknown
+// fields have no names on the wire, so each message is reported once
+func unknownProtoFields(message *analyticspb.WebhookPayload) []Violation {
+	var violations []Violation
+	report := func(pointer string, unknown protoreflect.RawFields) {
+		if len(unknown) > 0 {
+			violations = append(violations, Violation{
+				Pointer: pointer,
+				Rule:    RuleUnknownField,
+				Message: fmt.Sprintf("%d bytes of unknown fields", len(unknown)),
+			})
+		}
+	}
+
+	report("", message.ProtoReflect().GetUnknown())
+	if message.GetData() != nil {
+		report("/data", message.GetData().ProtoReflect().GetUnknown())
+	}
+	for i, record := range message.GetRecords() {
+		report(fmt.Sprintf("/records/%d", i), record.ProtoReflect().GetUnknown())
+	}
+	return violations
+}
+
+// MarshalProtobuf encodes a payload in the protobuf wire format, for senders
+// and tooling written in Go
+func MarshalProtobuf(payload *WebhookPayload) ([]byte, error) {
+	message := &analyticspb.WebhookPayload{
+		EventType:     payload.EventType,
+		Timestamp:     payload.Timestamp,
+		SchemaVersion: int64(payload.SchemaVersion),
+		Data:          recordToProto(payload.Data),
+	}
+	for _, record := range payload.Records {
+		message.Records = append(message.Records, recordToProto(record))
+	}
+	return proto.Marshal(message)
+}
+
+// recordFromProto maps a wire record onto the domain record; a missing
+// message yields the zero record, as an absent JSON "data" does
+func recordFromProto(r *analyticspb.AnalyticsRecord, version int) AnalyticsRecord {
+	return AnalyticsRecord{
+		RequestID:     r.GetRequestId(),
+		Query:         r.GetQuery(),
+		MatchType:     r.GetMatchType(),
+		MatchScore:    int(r.GetMatchScore()),
+		Reasoning:     r.GetReasoning(),
+		VectorMatches: int(r.GetVectorMatches()),
+		SessionID:     r.GetSessionId(),
+		Week:          r.GetWeek(),
+		Timestamp:     r.GetTimestamp(),
+		SchemaVersion: version,
+	}
+}
+
+// recordToProto maps a domain record onto the wire record
+func recordToProto(r AnalyticsRecord) *analyticspb.AnalyticsRecord {
+	return &analyticspb.AnalyticsRecord{
+		RequestId:     r.RequestID,
+		Query:         r.Query,
+		MatchType:     r.MatchType,
+		MatchScore:    int64(r.MatchScore),
+		Reasoning:     r.Reasoning,
+		VectorMatches: int64(r.VectorMatches),
+		SessionId:     r.SessionID,
+		Week:          r.Week,
+		Timestamp:     r.Timestamp,
+	}
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProtobufAndJSONDecodeIdentically(t *testing.T) {
+	record := AnalyticsRecord{
+		RequestID:     "req_123",
+		Query:         "Do you have Go experience? ✓",
+		MatchType:     "full",
+		MatchScore:    95,
+		Reasoning:     "Strong match",
+		VectorMatches: 5,
+		SessionID:     "sess_789",
+		Week:          "2023-W46",
+		Timestamp:     1700000000000,
+		SchemaVersion: CurrentSchemaVersion,
+	}
+	current := AnalyticsRecord{SchemaVersion: CurrentSchemaVersion}
+	cases := map[string]WebhookPayload{
+		"single event": {EventType: "analytics_event", Timestamp: 1700000000000, SchemaVersion: CurrentSchemaVersion, Data: record},
+		"batch": {EventType: EventTypeBatch, Timestamp: 1700000000000, SchemaVersion: CurrentSchemaVersion, Data: current,
+			Records: []AnalyticsRecord{record, {RequestID: "req_456", SchemaVersion: CurrentSchemaVersion}}},
+		"zero values": {EventType: "analytics_event", SchemaVersion: CurrentSchemaVersion, Data: current},
+	}
+	formats := NewDefaultPayloadFormats(DecodePermissive)
+
+	for name, original := range cases {
+		// Arrange
+		jsonBody, _ := json.Marshal(original)
+		protoBody, err := MarshalProtobuf(&original)
+		if err != nil {
+			t.Fatalf("%s: expected protobuf encoding, got %v", name, err)
+		}
+
+		jsonHeaders := http.Header{}
+		jsonHeaders.Set(ContentTypeHeader, "application/json")
+		protoHeaders := http.Header{}
+		protoHeaders.Set(ContentTypeHeader, ProtobufContentType)
+
+		// Act
+		fromJSON, jsonErr := formats.Parse(jsonBody, jsonHeaders)
+		fromProto, protoErr := formats.Parse(protoBody, protoHeaders)
+
+		// Assert
+		if jsonErr != nil || protoErr != nil {
+			t.Fatalf("%s: expected both to parse, got %v / %v", name, jsonErr, protoErr)
+		}
+		// Only JSON keeps the record as sent
+		fromJSON.Data.Raw = nil
+		for i := range fromJSON.Records {
+			fromJSON.Records[i].Raw = nil
+		}
+		if !reflect.DeepEqual(fromJSON, fromProto) {
+			t.Errorf("%s: decoded payloads differ\njson:  %+v\nproto: %+v", name, fromJSON, fromProto)
+		}
+		if !reflect.DeepEqual(*fromProto, original) {
+			t.Errorf("%s: round trip changed the payload\nwant: %+v\ngot:  %+v", name, original, *fromProto)
+		}
+	}
+}
+
+func TestProtobufParserRejectsMalformed(t *testing.T) {
+	headers := http.Header{}
+	headers.Set(ContentTypeHeader, ProtobufAltContentType)
+
+	_, err := NewDefaultPayloadFormats(DecodePermissive).Parse([]byte{0x1a, 0xff, 0x01}, headers)
+
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Violations[0].Rule != RuleSyntax {
+		t.Errorf("Expected a syntax ValidationError for a truncated message, got %v", err)
+	}
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	body, _ := json.Marshal(WebhookPayload{EventType: "analytics_event", Data: AnalyticsRecord{RequestID: "req_123", Query: "q", Reasoning: "r", Timestamp: 1700000000000}})
+	for i := 0; i < b.N; i++ {
+		JSONParser{}.Parse(body, http.Header{})
+	}
+}
+
+func BenchmarkParseProtobuf(b *testing.B) {
+	body, _ := MarshalProtobuf(&WebhookPayload{EventType: "analytics_event", Data: AnalyticsRecord{RequestID: "req_123", Query: "q", Reasoning: "r", Timestamp: 1700000000000}})
+	for i := 0; i < b.N; i++ {
+		ProtobufParser{}.Parse(body, http.Header{})
+	}
+}
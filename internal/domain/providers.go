@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Third-party sender signature headers
+const (
+	GitHubSignatureHeader = "X-Hub-Signature-256"
+	StripeSignatureHeader = "Stripe-Signature"
+	SlackSignatureHeader  = "X-Slack-Signature"
+	SlackTimestampHeader  = "X-Slack-Request-Timestamp"
+)
+
+// GitHubValidator implements SignatureValidator for GitHub webhooks
+// X-Hub-Signature-256 is "sha256=<hex>" over the body; GitHub sends no timestamp
+type GitHubValidator struct {
+	keys   []SigningKey
+	logger Logger
+	now    func() time.Time
+}
+
+// NewGitHubValidator creates a GitHub signature validator
+func NewGitHubValidator(keys []SigningKey, logger Logger) *GitHubValidator {
+	return &GitHubValidator{keys: keys, logger: logger, now: time.Now}
+}
+
+// Validate checks the X-Hub-Signature-256 header
+func (v *GitHubValidator) Validate(payload []byte, headers Headers) error {
+	signature, ok := strings.CutPrefix(headers.Get(GitHubSignatureHeader), "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	matchedID := matchHMAC(v.keys, v.now(), payload, []string{signature})
+	if matchedID == "" {
+		return ErrInvalidSignature
+	}
+
+	v.logger.Info("webhook signature matched", "scheme", "github", "keyId", matchedID)
+	return nil
+}
+
+// StripeValidator implements SignatureValidator for Stripe webhooks
+// Stripe-Signature is "t=<unix>,v1=<hex>[,v1=<hex>...]" over "<t>.<body>"
+type StripeValidator struct {
+	keys      []SigningKey
+	tolerance time.Duration
+	logger    Logger
+	now       func() time.Time
+}
+
+// NewStripeValidator creates a Stripe signature validator
+func NewStripeValidator(keys []SigningKey, tolerance time.Duration, logger Logger) *StripeValidator {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &StripeValidator{keys: keys, tolerance: tolerance, logger: logger, now: time.Now}
+}
+
+// Validate checks the Stripe-Signature header
+func (v *StripeValidator) Validate(payload []byte, headers Headers) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(headers.Get(StripeSignatureHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	now := v.now()
+	if err := checkTimestamp(timestamp, now, v.tolerance); err != nil {
+		return err
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("%w: no v1 signature in %s", ErrInvalidSignature, StripeSignatureHeader)
+	}
+
+	matchedID := matchHMAC(v.keys, now, timestampedContent(payload, timestamp), signatures)
+	if matchedID == "" {
+		return ErrInvalidSignature
+	}
+
+	v.logger.Info("webhook signature matched", "scheme", "stripe", "keyId", matchedID)
+	return nil
+}
+
+// SlackValidator implements SignatureValidator for Slack requests
+// X-Slack-Signature is "v0=<hex>" over "v0:<timestamp>:<body>"
+type SlackValidator struct {
+	keys      []SigningKey
+	tolerance time.Duration
+	logger    Logger
+	now       func() time.Time
+}
+
+// NewSlackValidator creates a Slack signature validator
+func NewSlackValidator(keys []SigningKey, tolerance time.Duration, logger Logger) *SlackValidator {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &SlackValidator{keys: keys, tolerance: tolerance, logger: logger, now: time.Now}
+}
+
+// Validate checks the X-Slack-Signature and X-Slack-Request-Timestamp headers
+func (v *SlackValidator) Validate(payload []byte, headers Headers) error {
+	timestamp := headers.Get(SlackTimestampHeader)
+
+	now := v.now()
+	if err := checkTimestamp(timestamp, now, v.tolerance); err != nil {
+		return err
+	}
+
+	signature, ok := strings.CutPrefix(headers.Get(SlackSignatureHeader), "v0=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	content := make([]byte, 0, len(timestamp)+4+len(payload))
+	content = append(content, "v0:"...)
+	content = append(content, timestamp...)
+	content = append(content, ':')
+	content = append(content, payload...)
+
+	matchedID := matchHMAC(v.keys, now, content, []string{signature})
+	if matchedID == "" {
+		return ErrInvalidSignature
+	}
+
+	v.logger.Info("webhook signature matched", "scheme", "slack", "keyId", matchedID)
+	return nil
+}
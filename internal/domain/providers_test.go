@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var providerKeys = []SigningKey{{ID: "current", Secret: "provider-secret"}}
+
+func TestGitHubValidator(t *testing.T) {
+	// Arrange
+	validator := NewGitHubValidator(providerKeys, &recordingLogger{})
+	payload := []byte(`{"action":"opened"}`)
+	headers := http.Header{}
+	headers.Set(GitHubSignatureHeader, "sha256="+sign("provider-secret", string(payload)))
+
+	// Act & Assert
+	if err := validator.Validate(payload, headers); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	headers.Set(GitHubSignatureHeader, sign("provider-secret", string(payload)))
+	if err := validator.Validate(payload, headers); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected missing sha256= prefix to be rejected, got %v", err)
+	}
+}
+
+func TestStripeValidator(t *testing.T) {
+	// Arrange
+	validator := NewStripeValidator(providerKeys, 5*time.Minute, &recordingLogger{})
+	validator.now = func() time.Time { return time.Unix(1700000000, 0) }
+	payload := []byte(`{"type":"charge.succeeded"}`)
+	valid := sign("provider-secret", "1700000000."+string(payload))
+
+	cases := map[string]struct {
+		header string
+		want   error
+	}{
+		"second v1 matches": {"t=1700000000,v1=deadbeef,v1=" + valid + ",v0=ignored", nil},
+		"stale timestamp":   {"t=1699990000,v1=" + valid, ErrTimestampOutOfTolerance},
+		"missing timestamp": {"v1=" + valid, ErrMissingTimestamp},
+		"no v1 signature":   {"t=1700000000,v0=" + valid, ErrInvalidSignature},
+	}
+
+	for name, tc := range cases {
+		headers := http.Header{}
+		headers.Set(StripeSignatureHeader, tc.header)
+
+		// Act
+		err := validator.Validate(payload, headers)
+
+		// Assert
+		if tc.want == nil && err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+		}
+		if tc.want != nil && !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestSlackValidator(t *testing.T) {
+	// Arrange
+	validator := NewSlackValidator(providerKeys, 5*time.Minute, &recordingLogger{})
+	validator.now = func() time.Time { return time.Unix(1700000000, 0) }
+	payload := []byte("token=xyz&team_id=T1")
+	headers := http.Header{}
+	headers.Set(SlackTimestampHeader, "1700000000")
+	headers.Set(SlackSignatureHeader, "v0="+sign("provider-secret", "v0:1700000000:"+string(payload)))
+
+	// Act & Assert
+	if err := validator.Validate(payload, headers); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	headers.Set(SlackTimestampHeader, "1700000001")
+	if err := validator.Validate(payload, headers); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected changed timestamp to be rejected, got %v", err)
+	}
+}
+
+func TestDefaultSchemeRegistry(t *testing.T) {
+	registry := NewDefaultSchemeRegistry()
+
+	for _, name := range []string{SchemeHMAC, SchemeStandardWebhooks, SchemeGitHub, SchemeStripe, SchemeSlack} {
+		scheme, err := registry.Get(name)
+		if err != nil {
+			t.Errorf("Expected scheme %s to be registered, got %v", name, err)
+			continue
+		}
+		if scheme.SignatureHeader == "" {
+			t.Errorf("Expected scheme %s to declare a signature header", name)
+		}
+	}
+
+	if _, err := registry.Get("unknown"); err == nil {
+		t.Errorf("Expected error for unknown scheme, got nil")
+	}
+}
@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultRecordSchema is the JSON Schema records are validated against when
+// no RECORD_SCHEMA_FILE is configured
+//
+//go:embed schemas/analytics_record.schema.json
+var DefaultRecordSchema []byte
+
+// recordSchemaURL names the compiled schema in error messages
+const recordSchemaURL = "analytics_record.schema.json"
+
+// Violation is one failed schema rule
+// Pointer is a JSON Pointer into the record (e.g. "/query"), Rule the schema keyword
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a record
+// It matches ErrInvalidPayload with errors.Is
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = fmt.Sprintf("%s: %s: %s", v.Pointer, v.Rule, v.Message)
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidPayload, strings.Join(parts, "; "))
+}
+
+// Summary names the failing fields, for reports that list violations separately
+func (e *ValidationError) Summary() string {
+	pointers := make([]string, 0, len(e.Violations))
+	seen := make(map[string]bool)
+	for _, v := range e.Violations {
+		pointer := v.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		if !seen[pointer] {
+			seen[pointer] = true
+			pointers = append(pointers, pointer)
+		}
+	}
+	return "schema violation at " + strings.Join(pointers, ", ")
+}
+
+// Unwrap lets errors.Is match ErrInvalidPayload
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidPayload
+}
+
+// Rejection splits a record's rejection error into a reason and, for schema
+// failures, the individual violations
+func Rejection(err error) (string, []Violation) {
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		return validation.Summary(), validation.Violations
+	}
+	return err.Error(), nil
+}
+
+// RecordValidator interface (Dependency Inversion Principle)
+// Checks a decoded record before it is stored; validators may correct it in place
+type RecordValidator interface {
+	ValidateRecord(record *AnalyticsRecord) error
+}
+
+// PatchValidator is implemented by record validators that can also check an
+// update, where only the fields the patch sets (see PatchFields) are present
+type PatchValidator interface {
+	ValidatePatch(patch *AnalyticsRecord) error
+}
+
+// JSONSchemaValidator implements RecordValidator with a JSON Schema document
+type JSONSchemaValidator struct {
+	schema *jsonschema.Schema
+}
+
+// NewJSONSchemaValidator compiles document (draft 2020-12 unless it sets $schema)
+func NewJSONSchemaValidator(document []byte) (*JSONSchemaValidator, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft2020
+	if err := compiler.AddResource(recordSchemaURL, bytes.NewReader(document)); err != nil {
+		return nil, fmt.Errorf("record schema: %w", err)
+	}
+	schema, err := compiler.Compile(recordSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("record schema: %w", err)
+	}
+	return &JSONSchemaValidator{schema: schema}, nil
+}
+
+// ValidateRecord implements RecordValidator
+// Returns a *ValidationError listing every violation, not just the first
+// The record's Raw JSON is checked when set; otherwise (e.g. protobuf, or
+// after a correction) the struct is re-encoded
+func (v *JSONSchemaValidator) ValidateRecord(record *AnalyticsRecord) error {
+	encoded := []byte(record.Raw)
+	if len(encoded) == 0 {
+		var err error
+		if encoded, err = json.Marshal(record); err != nil {
+			return err
+		}
+	}
+	// The validator needs json.Number to check integers exactly
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var instance interface{}
+	if err := decoder.Decode(&instance); err != nil {
+		return err
+	}
+
+	err := v.schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var schemaErr *jsonschema.ValidationError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	validation := &ValidationError{}
+	collectViolations(schemaErr, validation)
+	return validation
+}
+
+// collectViolations flattens the library's error tree to its leaves, which
+// name the failing keyword
+func collectViolations(err *jsonschema.ValidationError, into *ValidationError) {
+	if len(err.Causes) == 0 {
+		keyword := err.KeywordLocation
+		into.Violations = append(into.Violations, Violation{
+			Pointer: err.InstanceLocation,
+			Rule:    keyword[strings.LastIndex(keyword, "/")+1:],
+			Message: err.Message,
+		})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectViolations(cause, into)
+	}
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJSONSchemaValidatorListsEveryViolation(t *testing.T) {
+	// Arrange
+	validator, err := NewJSONSchemaValidator(DefaultRecordSchema)
+	if err != nil {
+		t.Fatalf("Expected default schema to compile, got %v", err)
+	}
+
+	// Act
+	err = validator.ValidateRecord(&AnalyticsRecord{MatchScore: 90})
+
+	// Assert
+	var validation *ValidationError
+	if !errors.As(err, &validation) || !errors.Is(err, ErrInvalidPayload) {
+		t.Fatalf("Expected a ValidationError matching ErrInvalidPayload, got %v", err)
+	}
+	got := make(map[string]string)
+	for _, v := range validation.Violations {
+		got[v.Pointer] = v.Rule
+	}
+	want := map[string]string{"/requestId": "minLength", "/query": "minLength", "/timestamp": "minimum"}
+	for pointer, rule := range want {
+		if got[pointer] != rule {
+			t.Errorf("Expected %s to fail %s, got %v", pointer, rule, validation.Violations)
+		}
+	}
+	if len(validation.Violations) != len(want) {
+		t.Errorf("Expected %d violations, got %v", len(want), validation.Violations)
+	}
+}
+
+func TestJSONSchemaValidatorChecksRawRecord(t *testing.T) {
+	// Arrange: the sender left out query, which the struct would encode as ""
+	validator, err := NewJSONSchemaValidator(DefaultRecordSchema)
+	if err != nil {
+		t.Fatalf("Expected default schema to compile, got %v", err)
+	}
+	record, err := NewDefaultSchemaVersions().DecodeRecord([]byte(`{"requestId":"r1","timestamp":1700000000}`), 0)
+	if err != nil {
+		t.Fatalf("Expected record to decode, got %v", err)
+	}
+
+	// Act
+	_, violations := Rejection(validator.ValidateRecord(&record))
+
+	// Assert
+	if len(violations) != 1 || violations[0].Rule != "required" {
+		t.Errorf("Expected the missing query to fail required, got %v", violations)
+	}
+}
+
+func TestDecodeRecordReportsWrongTypeByPointer(t *testing.T) {
+	// Act
+	_, err := NewDefaultSchemaVersions().DecodeRecord([]byte(`{"requestId":"r1","matchScore":"95"}`), 0)
+
+	// Assert
+	_, violations := Rejection(PrefixViolations(err, "/data"))
+	if !errors.Is(err, ErrInvalidPayload) || len(violations) != 1 || violations[0].Pointer != "/data/matchScore" || violations[0].Rule != RuleType {
+		t.Errorf("Expected a type violation at /data/matchScore, got %v", err)
+	}
+}
+
+func TestJSONSchemaValidatorCustomSchema(t *testing.T) {
+	// Arrange: a deployment that restricts matchType and bounds matchScore
+	validator, err := NewJSONSchemaValidator([]byte(`{
+		"type": "object",
+		"properties": {
+			"matchType": {"enum": ["full", "partial", "none"]},
+			"matchScore": {"type": "integer", "minimum": 0, "maximum": 100}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Expected schema to compile, got %v", err)
+	}
+	valid := AnalyticsRecord{RequestID: "r1", MatchType: "full", MatchScore: 95}
+	invalid := AnalyticsRecord{RequestID: "r2", MatchType: "fuzzy", MatchScore: 120}
+
+	// Act & Assert
+	if err := validator.ValidateRecord(&valid); err != nil {
+		t.Errorf("Expected valid record to pass, got %v", err)
+	}
+	_, violations := Rejection(validator.ValidateRecord(&invalid))
+	if len(violations) != 2 || violations[0].Pointer == violations[1].Pointer {
+		t.Errorf("Expected matchType and matchScore violations, got %v", violations)
+	}
+}
+
+func TestNewJSONSchemaValidatorRejectsBadSchema(t *testing.T) {
+	for name, document := range map[string]string{
+		"not json":     `{"type":`,
+		"bad keyword":  `{"type": "record"}`,
+		"bad property": `{"properties": {"query": {"minLength": -1}}}`,
+	} {
+		if _, err := NewJSONSchemaValidator([]byte(document)); err == nil {
+			t.Errorf("%s: expected compile error, got nil", name)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RecordRedactor interface (Dependency Inversion Principle)
+// Removes personal data from a record's free text before it is validated,
+// logged or stored
+type RecordRedactor interface {
+	Redact(record *AnalyticsRecord)
+}
+
+// RedactMode is how a detected match is replaced
+type RedactMode string
+
+// Redaction modes
+const (
+	// RedactMask replaces a match with its rule name, e.g. "[redacted:email]"
+	RedactMask RedactMode = "mask"
+	// RedactHash replaces a match with a keyed hash, e.g. "[email:1f2e3d4c5b6a]",
+	// so repeated values can still be counted without being readable
+	RedactHash RedactMode = "hash"
+)
+
+// Redaction rule names, recorded in AnalyticsRecord.Redactions
+const (
+	RedactionEmail    = "email"
+	RedactionPhone    = "phone"
+	RedactionCard     = "card"
+	RedactionDenyList = "denyList"
+)
+
+// hashLength is the number of hex characters kept from a hashed match
+const hashLength = 12
+
+// Detector finds one kind of personal data in text
+// Find returns the [start, end) byte offsets of each match
+type Detector interface {
+	Name() string
+	Find(text string) [][]int
+}
+
+// RegexDetector matches a pattern, optionally confirmed by valid
+type RegexDetector struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// NewRegexDetector creates a detector; valid may be nil
+func NewRegexDetector(name string, pattern *regexp.Regexp, valid func(match string) bool) *RegexDetector {
+	return &RegexDetector{name: name, pattern: pattern, valid: valid}
+}
+
+// Name implements Detector
+func (d *RegexDetector) Name() string {
+	return d.name
+}
+
+// Find implements Detector
+func (d *RegexDetector) Find(text string) [][]int {
+	var matches [][]int
+	for _, match := range d.pattern.FindAllStringIndex(text, -1) {
+		if d.valid == nil || d.valid(text[match[0]:match[1]]) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	cardPattern  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d ().-]{6,}\d`)
+	datePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// DefaultDetectors finds card numbers, emails and phone numbers
+// Cards come first so a card number is not reported as a phone number
+func DefaultDetectors() []Detector {
+	return []Detector{
+		NewRegexDetector(RedactionCard, cardPattern, luhnValid),
+		NewRegexDetector(RedactionEmail, emailPattern, nil),
+		NewRegexDetector(RedactionPhone, phonePattern, func(match string) bool {
+			digits := countDigits(match)
+			return digits >= 8 && digits <= 15 && !datePattern.MatchString(match)
+		}),
+	}
+}
+
+// NewDenyListDetector matches any of terms as whole words, ignoring case
+// Returns nil when terms is empty
+func NewDenyListDetector(terms []string) Detector {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	// Longer terms first, so "Ada Lovelace" wins over "Ada"
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	pattern := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return NewRegexDetector(RedactionDenyList, pattern, nil)
+}
+
+// Redactor implements RecordRedactor over query, reasoning and the strings in Extra
+type Redactor struct {
+	detectors []Detector
+	mode      RedactMode
+	hashKey   []byte
+	logger    Logger
+}
+
+// NewRedactor creates a redactor; hashKey is required in RedactHash mode
+// The key keeps hashes of short values such as phone numbers from being
+// reversed by hashing every candidate
+func NewRedactor(detectors []Detector, mode RedactMode, hashKey []byte, logger Logger) (*Redactor, error) {
+	switch mode {
+	case RedactMask:
+	case RedactHash:
+		if len(hashKey) == 0 {
+			return nil, fmt.Errorf("redaction mode %q needs a hash key", mode)
+		}
+	default:
+		return nil, fmt.Errorf("redaction mode must be %q or %q, got %q", RedactMask, RedactHash, mode)
+	}
+	return &Redactor{detectors: detectors, mode: mode, hashKey: hashKey, logger: logger}, nil
+}
+
+// Redact implements RecordRedactor
+// The rules that fired are recorded in record.Redactions and logged by name;
+// the matched text is never logged
+func (r *Redactor) Redact(record *AnalyticsRecord) {
+	fired := make(map[string]bool)
+	record.Query = r.redact(record.Query, fired)
+	record.Reasoning = r.redact(record.Reasoning, fired)
+	for key, value := range record.Extra {
+		record.Extra[key] = r.redactValue(value, fired)
+	}
+	if len(fired) == 0 {
+		return
+	}
+
+	for rule := range fired {
+		record.Redactions = append(record.Redactions, rule)
+	}
+	sort.Strings(record.Redactions)
+	r.logger.Info("analytics record redacted", "requestId", record.RequestID, "rules", strings.Join(record.Redactions, ","))
+}
+
+// redact replaces every match in text, adding the rules that fired to fired
+// Overlapping matches go to the earliest, then longest, then first detector
+func (r *Redactor) redact(text string, fired map[string]bool) string {
+	if text == "" {
+		return text
+	}
+
+	type match struct {
+		start, end int
+		rule       string
+	}
+	var matches []match
+	for _, detector := range r.detectors {
+		for _, m := range detector.Find(text) {
+			matches = append(matches, match{m[0], m[1], detector.Name()})
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+
+	var out strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.start < last {
+			continue
+		}
+		out.WriteString(text[last:m.start])
+		out.WriteString(r.replacement(m.rule, text[m.start:m.end]))
+		fired[m.rule] = true
+		last = m.end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// redactValue redacts every string in a decoded JSON value
+func (r *Redactor) redactValue(value interface{}, fired map[string]bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.redact(v, fired)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = r.redactValue(item, fired)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactValue(item, fired)
+		}
+	}
+	return value
+}
+
+// replacement masks or hashes one match
+func (r *Redactor) replacement(rule, match string) string {
+	if r.mode == RedactHash {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(match))
+		return "[" + rule + ":" + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+	}
+	return "[redacted:" + rule + "]"
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum, which
+// every payment card number does
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// countDigits counts the ASCII digits in s
+func countDigits(s string) int {
+	count := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T, mode RedactMode, logger Logger, denyList ...string) *Redactor {
+	t.Helper()
+	detectors := DefaultDetectors()
+	if d := NewDenyListDetector(denyList); d != nil {
+		detectors = append(detectors, d)
+	}
+	redactor, err := NewRedactor(detectors, mode, []byte("test-key"), logger)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return redactor
+}
+
+func TestRedactorMasksDetectedData(t *testing.T) {
+	cases := map[string]struct {
+		query string
+		want  string
+		rules []string
+	}{
+		"email": {
+			query: "mail me at jane.doe@example.co.uk please",
+			want:  "mail me at [redacted:email] please",
+			rules: []string{RedactionEmail},
+		},
+		"phone": {
+			query: "call +44 (20) 7946-0958 tomorrow",
+			want:  "call [redacted:phone] tomorrow",
+			rules: []string{RedactionPhone},
+		},
+		"card wins over phone": {
+			query: "card 4111 1111 1111 1111 ok",
+			want:  "card [redacted:card] ok",
+			rules: []string{RedactionCard},
+		},
+		"deny-listed name, any case": {
+			query: "is ada lovelace available?",
+			want:  "is [redacted:denyList] available?",
+			rules: []string{RedactionDenyList},
+		},
+		"dates and short numbers are kept": {
+			query: "jobs since 2023-10-31 with 5 years of Go 1.22",
+			want:  "jobs since 2023-10-31 with 5 years of Go 1.22",
+		},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		redactor := newTestRedactor(t, RedactMask, &recordingLogger{}, "Ada Lovelace")
+		record := AnalyticsRecord{RequestID: "r1", Query: tc.query}
+
+		// Act
+		redactor.Redact(&record)
+
+		// Assert
+		if record.Query != tc.want {
+			t.Errorf("%s: expected %q, got %q", name, tc.want, record.Query)
+		}
+		if !reflect.DeepEqual(record.Redactions, tc.rules) {
+			t.Errorf("%s: expected rules %v, got %v", name, tc.rules, record.Redactions)
+		}
+	}
+}
+
+func TestRedactorRedactsExtraFields(t *testing.T) {
+	// Arrange: a misspelled field lands in extra, but may still hold PII
+	redactor := newTestRedactor(t, RedactMask, &recordingLogger{})
+	record := AnalyticsRecord{RequestID: "r1", Extra: map[string]interface{}{
+		"qeury":   "mail me at jane@example.com",
+		"contact": map[string]interface{}{"phones": []interface{}{"+44 20 7946 0958"}},
+		"count":   float64(3),
+	}}
+
+	// Act
+	redactor.Redact(&record)
+
+	// Assert
+	want := map[string]interface{}{
+		"qeury":   "mail me at [redacted:email]",
+		"contact": map[string]interface{}{"phones": []interface{}{"[redacted:phone]"}},
+		"count":   float64(3),
+	}
+	if !reflect.DeepEqual(record.Extra, want) {
+		t.Errorf("Expected extra %v, got %v", want, record.Extra)
+	}
+	if !reflect.DeepEqual(record.Redactions, []string{RedactionEmail, RedactionPhone}) {
+		t.Errorf("Expected email and phone rules, got %v", record.Redactions)
+	}
+}
+
+func TestRedactorHashesAndNeverLogsOriginal(t *testing.T) {
+	// Arrange
+	logger := &recordingLogger{}
+	redactor := newTestRedactor(t, RedactHash, logger)
+	first := AnalyticsRecord{RequestID: "r1", Query: "I am jane@example.com", Reasoning: "asked by jane@example.com"}
+	second := AnalyticsRecord{RequestID: "r2", Query: "I am jane@example.com"}
+
+	// Act
+	redactor.Redact(&first)
+	redactor.Redact(&second)
+
+	// Assert: the same value hashes the same way, so it can still be counted
+	if first.Query != second.Query || !strings.HasPrefix(first.Query, "I am [email:") {
+		t.Errorf("Expected equal keyed hashes, got %q and %q", first.Query, second.Query)
+	}
+	if !reflect.DeepEqual(first.Redactions, []string{RedactionEmail}) {
+		t.Errorf("Expected the email rule once, got %v", first.Redactions)
+	}
+	if logged := fmt.Sprint(logger.InfoArgs); strings.Contains(logged, "jane@example.com") || !strings.Contains(logged, RedactionEmail) {
+		t.Errorf("Expected rule names but no original text in logs, got %s", logged)
+	}
+}
+
+func TestNewRedactorRequiresHashKey(t *testing.T) {
+	if _, err := NewRedactor(DefaultDetectors(), RedactHash, nil, &recordingLogger{}); err == nil {
+		t.Error("Expected hash mode without a key to be rejected")
+	}
+}
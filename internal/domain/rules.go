@@ -0,0 +1,366 @@
+package domain
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultRules is the rule set applied when no RULES_FILE is configured
+//
+//go:embed schemas/analytics_rules.json
+var DefaultRules []byte
+
+// RuleAction is what a rule does when a record breaks it
+type RuleAction string
+
+// Rule actions
+const (
+	// RuleReject fails the record with a ValidationError
+	RuleReject RuleAction = "reject"
+	// RuleWarn stores the record unchanged and logs the finding
+	RuleWarn RuleAction = "warn"
+	// RuleCorrect fixes the field (clamp, truncate, default or derive) and logs the change
+	RuleCorrect RuleAction = "correct"
+)
+
+// Cross-field checks selectable with Rule.Check
+const (
+	// CheckMillisecondTimestamp flags timestamps that look like seconds;
+	// the correction multiplies by 1000
+	CheckMillisecondTimestamp = "millisecondTimestamp"
+	// CheckWeekMatchesTimestamp flags a week that is not the ISO week (UTC) of
+	// the timestamp; the correction derives it from the timestamp
+	CheckWeekMatchesTimestamp = "weekMatchesTimestamp"
+)
+
+// secondsCutoff separates second and millisecond timestamps: as milliseconds
+// it is March 1973, as seconds it is the year 5138
+const secondsCutoff = 100_000_000_000
+
+// Rule is one declarative check on a record field. Exactly one of the
+// range (Min/Max), Enum, Pattern, MaxLength or Check forms is set
+type Rule struct {
+	Name      string     `json:"name"`
+	Field     string     `json:"field"`
+	Action    RuleAction `json:"action"`
+	Min       *int64     `json:"min,omitempty"`
+	Max       *int64     `json:"max,omitempty"`
+	Enum      []string   `json:"enum,omitempty"`
+	Default   string     `json:"default,omitempty"` // replaces a value outside Enum when correcting
+	Pattern   string     `json:"pattern,omitempty"`
+	MaxLength int        `json:"maxLength,omitempty"`
+	Check     string     `json:"check,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ruleFile is the RULES_FILE document
+type ruleFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// intField and stringField address the record fields rules may name
+type intField struct {
+	get func(*AnalyticsRecord) int64
+	set func(*AnalyticsRecord, int64)
+}
+
+var intFields = map[string]intField{
+	"matchScore": {
+		func(r *AnalyticsRecord) int64 { return int64(r.MatchScore) },
+		func(r *AnalyticsRecord, v int64) { r.MatchScore = int(v) },
+	},
+	"vectorMatches": {
+		func(r *AnalyticsRecord) int64 { return int64(r.VectorMatches) },
+		func(r *AnalyticsRecord, v int64) { r.VectorMatches = int(v) },
+	},
+	"timestamp": {
+		func(r *AnalyticsRecord) int64 { return r.Timestamp },
+		func(r *AnalyticsRecord, v int64) { r.Timestamp = v },
+	},
+}
+
+var stringFields = map[string]func(*AnalyticsRecord) *string{
+	"requestId": func(r *AnalyticsRecord) *string { return &r.RequestID },
+	"query":     func(r *AnalyticsRecord) *string { return &r.Query },
+	"matchType": func(r *AnalyticsRecord) *string { return &r.MatchType },
+	"reasoning": func(r *AnalyticsRecord) *string { return &r.Reasoning },
+	"sessionId": func(r *AnalyticsRecord) *string { return &r.SessionID },
+	"week":      func(r *AnalyticsRecord) *string { return &r.Week },
+}
+
+// ParseRules reads a rule document ({"rules": [...]}) and checks every rule
+// names a known field, action and form
+func ParseRules(document []byte) ([]Rule, error) {
+	var file ruleFile
+	if err := json.Unmarshal(document, &file); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		seen[rule.Name] = true
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// compile validates the rule's form against its field type
+func (r *Rule) compile() error {
+	switch r.Action {
+	case RuleReject, RuleWarn, RuleCorrect:
+	default:
+		return fmt.Errorf("action must be %q, %q or %q, got %q", RuleReject, RuleWarn, RuleCorrect, r.Action)
+	}
+
+	forms := 0
+	for _, set := range []bool{r.Min != nil || r.Max != nil, len(r.Enum) > 0, r.Pattern != "", r.MaxLength > 0, r.Check != ""} {
+		if set {
+			forms++
+		}
+	}
+	if forms != 1 {
+		return fmt.Errorf("must set exactly one of min/max, enum, pattern, maxLength or check")
+	}
+
+	_, isInt := intFields[r.Field]
+	_, isString := stringFields[r.Field]
+	switch {
+	case r.Min != nil || r.Max != nil:
+		if !isInt {
+			return fmt.Errorf("min/max need an integer field, got %q", r.Field)
+		}
+	case r.Check == CheckMillisecondTimestamp:
+		if r.Field != "timestamp" {
+			return fmt.Errorf("check %q applies to timestamp, got %q", r.Check, r.Field)
+		}
+	case r.Check == CheckWeekMatchesTimestamp:
+		if r.Field != "week" {
+			return fmt.Errorf("check %q applies to week, got %q", r.Check, r.Field)
+		}
+	case r.Check != "":
+		return fmt.Errorf("unknown check %q", r.Check)
+	default:
+		if !isString {
+			return fmt.Errorf("enum, pattern and maxLength need a string field, got %q", r.Field)
+		}
+	}
+
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return err
+		}
+		r.pattern = pattern
+	}
+	return nil
+}
+
+// evaluate checks the rule; on failure it returns a message and, when the
+// rule can fix the record, the correction
+func (r *Rule) evaluate(record *AnalyticsRecord) (string, func()) {
+	switch {
+	case r.Min != nil || r.Max != nil:
+		field := intFields[r.Field]
+		value := field.get(record)
+		if r.Min != nil && value < *r.Min {
+			return fmt.Sprintf("must be >= %d, got %d", *r.Min, value), func() { field.set(record, *r.Min) }
+		}
+		if r.Max != nil && value > *r.Max {
+			return fmt.Sprintf("must be <= %d, got %d", *r.Max, value), func() { field.set(record, *r.Max) }
+		}
+
+	case r.Check == CheckMillisecondTimestamp:
+		if record.Timestamp > 0 && record.Timestamp < secondsCutoff {
+			return fmt.Sprintf("looks like seconds, expected milliseconds, got %d", record.Timestamp),
+				func() { record.Timestamp *= 1000 }
+		}
+
+	case r.Check == CheckWeekMatchesTimestamp:
+		if record.Timestamp <= 0 {
+			return "", nil
+		}
+		want := isoWeek(record.Timestamp)
+		if record.Week != want {
+			return fmt.Sprintf("must be the ISO week of timestamp (%s), got %q", want, record.Week),
+				func() { record.Week = want }
+		}
+
+	default:
+		value := stringFields[r.Field](record)
+		if *value == "" {
+			return "", nil
+		}
+		switch {
+		case len(r.Enum) > 0:
+			for _, allowed := range r.Enum {
+				if *value == allowed {
+					return "", nil
+				}
+			}
+			return fmt.Sprintf("must be one of %s, got %q", strings.Join(r.Enum, ", "), *value),
+				func() { *value = r.Default }
+		case r.pattern != nil:
+			if !r.pattern.MatchString(*value) {
+				return fmt.Sprintf("must match %s, got %q", r.Pattern, *value), func() { *value = "" }
+			}
+		case utf8.RuneCountInString(*value) > r.MaxLength:
+			return fmt.Sprintf("must be at most %d characters, got %d", r.MaxLength, utf8.RuneCountInString(*value)),
+				func() { *value = truncateRunes(*value, r.MaxLength) }
+		}
+	}
+	return "", nil
+}
+
+// RuleSet implements RecordValidator with semantic rules, applied in order so
+// a correction (e.g. seconds to milliseconds) is seen by later rules
+type RuleSet struct {
+	rules  []Rule
+	logger Logger
+}
+
+// NewRuleSet creates a rule set from parsed rules
+func NewRuleSet(rules []Rule, logger Logger) *RuleSet {
+	return &RuleSet{rules: rules, logger: logger}
+}
+
+// ValidateRecord implements RecordValidator
+// Corrections are applied to record; every rejecting rule is reported in one
+// ValidationError
+func (s *RuleSet) ValidateRecord(record *AnalyticsRecord) error {
+	return s.apply(record, func(*Rule) bool { return true })
+}
+
+// ValidatePatch implements PatchValidator
+// Only rules on the fields the patch sets are applied
+func (s *RuleSet) ValidatePatch(patch *AnalyticsRecord) error {
+	set := PatchFields(*patch)
+	return s.apply(patch, func(rule *Rule) bool {
+		_, ok := set[rule.Field]
+		return ok
+	})
+}
+
+// apply evaluates the rules selected by applies, in order
+func (s *RuleSet) apply(record *AnalyticsRecord, applies func(*Rule) bool) error {
+	var rejected []Violation
+	corrected := make(map[string]bool)
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !applies(rule) {
+			continue
+		}
+		message, correct := rule.evaluate(record)
+		if message == "" {
+			continue
+		}
+
+		switch rule.Action {
+		case RuleReject:
+			rejected = append(rejected, Violation{Pointer: "/" + rule.Field, Rule: rule.Name, Message: message})
+		case RuleWarn:
+			s.logger.Info("analytics record rule warning", "rule", rule.Name, "requestId", record.RequestID, "message", message)
+		case RuleCorrect:
+			correct()
+			corrected[rule.Field] = true
+			s.logger.Info("analytics record corrected", "rule", rule.Name, "requestId", record.RequestID, "message", message)
+		}
+	}
+	if len(corrected) > 0 {
+		record.Raw = correctRaw(record, corrected)
+	}
+
+	if len(rejected) > 0 {
+		return &ValidationError{Violations: rejected}
+	}
+	return nil
+}
+
+// correctRaw copies the corrected fields into the record's Raw JSON, so a
+// later schema check sees the corrections and still sees which fields the
+// sender left out. It returns nil (check the struct) if Raw cannot be rewritten
+func correctRaw(record *AnalyticsRecord, fields map[string]bool) json.RawMessage {
+	if len(record.Raw) == 0 {
+		return nil
+	}
+	var raw, current map[string]json.RawMessage
+	if err := json.Unmarshal(record.Raw, &raw); err != nil || raw == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(encoded, &current); err != nil {
+		return nil
+	}
+	for field := range fields {
+		raw[field] = current[field]
+	}
+	rewritten, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	return rewritten
+}
+
+// RecordValidators runs validators in order and stops at the first error
+// A validator may appear twice, e.g. the schema again after the rules, so
+// corrections are checked too
+type RecordValidators []RecordValidator
+
+// ValidateRecord implements RecordValidator
+func (v RecordValidators) ValidateRecord(record *AnalyticsRecord) error {
+	for _, validator := range v {
+		if err := validator.ValidateRecord(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isoWeek formats the ISO 8601 week of a millisecond timestamp, e.g. "2023-W44"
+func isoWeek(timestamp int64) string {
+	year, week := time.UnixMilli(timestamp).UTC().ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// truncateRunes shortens s to at most n characters without splitting one
+func truncateRunes(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// ValidatePatch implements PatchValidator with the validators that support patches
+func (v RecordValidators) ValidatePatch(patch *AnalyticsRecord) error {
+	for _, validator := range v {
+		patches, ok := validator.(PatchValidator)
+		if !ok {
+			continue
+		}
+		if err := patches.ValidatePatch(patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func defaultRuleSet(t *testing.T, logger Logger) *RuleSet {
+	t.Helper()
+	rules, err := ParseRules(DefaultRules)
+	if err != nil {
+		t.Fatalf("Expected default rules to parse, got %v", err)
+	}
+	return NewRuleSet(rules, logger)
+}
+
+func TestRuleSetDefaultRules(t *testing.T) {
+	// 1698765432000 is 2023-10-31T15:17:12Z, ISO week 2023-W44
+	base := AnalyticsRecord{RequestID: "r1", Query: "q", MatchType: "full", MatchScore: 80, Week: "2023-W44", Timestamp: 1698765432000}
+
+	cases := map[string]struct {
+		modify   func(*AnalyticsRecord)
+		want     func(*AnalyticsRecord)
+		rejected []string
+		warnings int
+	}{
+		"clean record is unchanged": {
+			modify: func(r *AnalyticsRecord) {},
+			want:   func(r *AnalyticsRecord) {},
+		},
+		"negative score and matches are rejected": {
+			modify:   func(r *AnalyticsRecord) { r.MatchScore = -5; r.VectorMatches = -1 },
+			rejected: []string{"matchScore-range", "vectorMatches-range"},
+		},
+		"seconds are corrected before the week is checked": {
+			modify: func(r *AnalyticsRecord) { r.Timestamp = 1698765432; r.Week = "2024-W43" },
+			want:   func(r *AnalyticsRecord) { r.Timestamp = 1698765432000; r.Week = "2023-W44" },
+		},
+		"unknown match type only warns": {
+			modify:   func(r *AnalyticsRecord) { r.MatchType = "fuzzy" },
+			want:     func(r *AnalyticsRecord) { r.MatchType = "fuzzy" },
+			warnings: 1,
+		},
+		"malformed week warns then is derived": {
+			modify:   func(r *AnalyticsRecord) { r.Week = "week 44" },
+			want:     func(r *AnalyticsRecord) {},
+			warnings: 1,
+		},
+		"long query is truncated on a character boundary": {
+			modify: func(r *AnalyticsRecord) { r.Query = strings.Repeat("é", 2001) },
+			want:   func(r *AnalyticsRecord) { r.Query = strings.Repeat("é", 2000) },
+		},
+	}
+
+	for name, tc := range cases {
+		// Arrange
+		logger := &recordingLogger{}
+		rules := defaultRuleSet(t, logger)
+		record := base
+		tc.modify(&record)
+
+		// Act
+		err := rules.ValidateRecord(&record)
+
+		// Assert
+		if len(tc.rejected) > 0 {
+			var validation *ValidationError
+			if !errors.As(err, &validation) || len(validation.Violations) != len(tc.rejected) {
+				t.Errorf("%s: expected %d violations, got %v", name, len(tc.rejected), err)
+				continue
+			}
+			for i, rule := range tc.rejected {
+				if validation.Violations[i].Rule != rule {
+					t.Errorf("%s: expected violation %d to be %s, got %+v", name, i, rule, validation.Violations[i])
+				}
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", name, err)
+			continue
+		}
+		want := base
+		tc.want(&want)
+		if !reflect.DeepEqual(record, want) {
+			t.Errorf("%s: expected %+v, got %+v", name, want, record)
+		}
+		// Log args are "rule", name, ...; the default warn rules are the enum and format checks
+		warnings := 0
+		for _, args := range logger.InfoArgs {
+			if args[1] == "matchType-enum" || args[1] == "week-format" {
+				warnings++
+			}
+		}
+		if warnings != tc.warnings {
+			t.Errorf("%s: expected %d warnings, got %d", name, tc.warnings, warnings)
+		}
+	}
+}
+
+func TestRuleSetEnumCorrectionUsesDefault(t *testing.T) {
+	rules, err := ParseRules([]byte(`{"rules":[{"name":"type","field":"matchType","enum":["full","none"],"default":"none","action":"correct"}]}`))
+	if err != nil {
+		t.Fatalf("Expected rules to parse, got %v", err)
+	}
+	record := AnalyticsRecord{MatchType: "fuzzy"}
+
+	err = NewRuleSet(rules, &recordingLogger{}).ValidateRecord(&record)
+
+	if err != nil || record.MatchType != "none" {
+		t.Errorf("Expected matchType corrected to none, got %q (%v)", record.MatchType, err)
+	}
+}
+
+func TestSchemaRecheckCatchesCorrections(t *testing.T) {
+	// Arrange: a pattern correction empties requestId, which the schema requires
+	rules, err := ParseRules([]byte(`{"rules":[{"name":"id","field":"requestId","pattern":"^req_","action":"correct"}]}`))
+	if err != nil {
+		t.Fatalf("Expected rules to parse, got %v", err)
+	}
+	schema, err := NewJSONSchemaValidator(DefaultRecordSchema)
+	if err != nil {
+		t.Fatalf("Expected default schema to compile, got %v", err)
+	}
+	record, err := NewDefaultSchemaVersions().DecodeRecord([]byte(`{"requestId":"r1","query":"q","timestamp":1700000000000}`), 0)
+	if err != nil {
+		t.Fatalf("Expected record to decode, got %v", err)
+	}
+
+	// Act
+	err = RecordValidators{schema, NewRuleSet(rules, &recordingLogger{}), schema}.ValidateRecord(&record)
+
+	// Assert
+	_, violations := Rejection(err)
+	if record.RequestID != "" || len(violations) != 1 || violations[0].Pointer != "/requestId" {
+		t.Errorf("Expected the corrected requestId to fail the schema, got %v", err)
+	}
+}
+
+func TestParseRulesRejectsInvalidRules(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":     `{"name":"a","field":"score","min":0,"action":"reject"}`,
+		"range on a string": `{"name":"a","field":"query","min":0,"action":"reject"}`,
+		"enum on a number":  `{"name":"a","field":"matchScore","enum":["1"],"action":"reject"}`,
+		"two forms":         `{"name":"a","field":"query","maxLength":5,"pattern":"x","action":"reject"}`,
+		"no form":           `{"name":"a","field":"query","action":"reject"}`,
+		"bad pattern":       `{"name":"a","field":"week","pattern":"(","action":"warn"}`,
+		"bad action":        `{"name":"a","field":"query","maxLength":5,"action":"drop"}`,
+		"unknown check":     `{"name":"a","field":"week","check":"isoDate","action":"warn"}`,
+		"check on a field":  `{"name":"a","field":"query","check":"weekMatchesTimestamp","action":"warn"}`,
+		"missing name":      `{"field":"query","maxLength":5,"action":"warn"}`,
+	}
+
+	for name, rule := range cases {
+		if _, err := ParseRules([]byte(`{"rules":[` + rule + `]}`)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
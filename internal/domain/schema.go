@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentSchemaVersion is the payload schema AnalyticsRecord describes
+// Payloads without a schemaVersion predate versioning and are version 1
+const CurrentSchemaVersion = 1
+
+// Upcaster migrates one record's raw JSON fields from version N to N+1 in place
+type Upcaster func(fields map[string]interface{}) error
+
+// SchemaVersions decodes records sent in any supported schema version by
+// running the upcasters from the arrival version up to the current one
+// A nil *SchemaVersions accepts only CurrentSchemaVersion
+type SchemaVersions struct {
+	current   int
+	upcasters map[int]Upcaster
+}
+
+// NewSchemaVersions creates a chain whose newest version is current
+func NewSchemaVersions(current int) *SchemaVersions {
+	return &SchemaVersions{current: current, upcasters: make(map[int]Upcaster)}
+}
+
+// NewDefaultSchemaVersions registers the upcasters for every version the
+// chatbot has shipped. When a new version is released, bump
+// CurrentSchemaVersion and register the upcaster from the previous one here
+func NewDefaultSchemaVersions() *SchemaVersions {
+	return NewSchemaVersions(CurrentSchemaVersion)
+}
+
+// Register sets the upcaster from version from to from+1
+func (s *SchemaVersions) Register(from int, upcaster Upcaster) {
+	s.upcasters[from] = upcaster
+}
+
+// Current returns the newest schema version
+func (s *SchemaVersions) Current() int {
+	if s == nil {
+		return CurrentSchemaVersion
+	}
+	return s.current
+}
+
+// Check normalizes version (0 means 1) and rejects versions outside 1..Current
+func (s *SchemaVersions) Check(version int) (int, error) {
+	if version == 0 {
+		version = 1
+	}
+	if version < 1 || version > s.Current() {
+		return 0, fmt.Errorf("%w: %d (supported 1-%d)", ErrUnsupportedSchemaVersion, version, s.Current())
+	}
+	return version, nil
+}
+
+// DecodeRecord decodes one record sent in version, upcasts it and records
+// the version it arrived in. A "schemaVersion" inside the record overrides version
+// Values of the wrong type are reported as a *ValidationError with a pointer
+// relative to the record
+func (s *SchemaVersions) DecodeRecord(raw []byte, version int) (AnalyticsRecord, error) {
+	// Older shapes may not fit the current field types, so read only the version first
+	var declared struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(raw, &declared); err != nil {
+		return AnalyticsRecord{}, DecodeError(err, "")
+	}
+	if declared.SchemaVersion != 0 {
+		version = declared.SchemaVersion
+	}
+
+	version, err := s.Check(version)
+	if err != nil {
+		return AnalyticsRecord{}, err
+	}
+
+	if version < s.Current() {
+		if raw, err = s.upcast(raw, version); err != nil {
+			return AnalyticsRecord{}, err
+		}
+	}
+
+	var record AnalyticsRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return AnalyticsRecord{}, DecodeError(err, "")
+	}
+	record.Raw = raw
+	if record.Extra, err = unknownFields(raw, recordFields, true); err != nil {
+		return AnalyticsRecord{}, err
+	}
+
+	record.SchemaVersion = version
+	return record, nil
+}
+
+// upcast runs the chain from version to Current over the raw fields and
+// returns the record in the current shape
+func (s *SchemaVersions) upcast(raw []byte, version int) ([]byte, error) {
+	// UseNumber keeps large integers such as millisecond timestamps exact
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+
+	for v := version; v < s.Current(); v++ {
+		upcaster, ok := s.upcasters[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: no upcaster from version %d", ErrUnsupportedSchemaVersion, v)
+		}
+		if err := upcaster(fields); err != nil {
+			return nil, fmt.Errorf("%w: upcasting version %d: %v", ErrInvalidPayload, v, err)
+		}
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testSchemaVersions is a three-version chain: v1 sent the score as a 0-1
+// fraction named "score", and v2 sent the match count as "matches"
+func testSchemaVersions() *SchemaVersions {
+	schemas := NewSchemaVersions(3)
+	schemas.Register(1, func(fields map[string]interface{}) error {
+		score, ok := fields["score"].(json.Number)
+		if !ok {
+			return fmt.Errorf("score must be a number")
+		}
+		fraction, err := score.Float64()
+		if err != nil {
+			return err
+		}
+		fields["matchScore"] = int(fraction*100 + 0.5)
+		delete(fields, "score")
+		return nil
+	})
+	schemas.Register(2, func(fields map[string]interface{}) error {
+		fields["vectorMatches"] = fields["matches"]
+		delete(fields, "matches")
+		return nil
+	})
+	return schemas
+}
+
+func TestSchemaVersionsDecodeRecord(t *testing.T) {
+	schemas := testSchemaVersions()
+	cases := map[string]struct {
+		raw     string
+		version int
+		want    AnalyticsRecord
+	}{
+		"unversioned is v1": {
+			raw:  `{"requestId":"r1","score":0.87,"matches":4,"timestamp":1700000000000}`,
+			want: AnalyticsRecord{RequestID: "r1", MatchScore: 87, VectorMatches: 4, Timestamp: 1700000000000, SchemaVersion: 1},
+		},
+		"v2 skips the first upcaster": {
+			raw:     `{"requestId":"r2","matchScore":60,"matches":2}`,
+			version: 2,
+			want:    AnalyticsRecord{RequestID: "r2", MatchScore: 60, VectorMatches: 2, SchemaVersion: 2},
+		},
+		"current is decoded as is": {
+			raw:     `{"requestId":"r3","matchScore":60,"vectorMatches":2}`,
+			version: 3,
+			want:    AnalyticsRecord{RequestID: "r3", MatchScore: 60, VectorMatches: 2, SchemaVersion: 3},
+		},
+		"record version overrides envelope": {
+			raw:     `{"requestId":"r4","schemaVersion":2,"matchScore":10,"matches":1}`,
+			version: 1,
+			want:    AnalyticsRecord{RequestID: "r4", MatchScore: 10, VectorMatches: 1, SchemaVersion: 2},
+		},
+	}
+
+	for name, tc := range cases {
+		// Act
+		record, err := schemas.DecodeRecord([]byte(tc.raw), tc.version)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if len(record.Raw) == 0 {
+			t.Errorf("%s: expected the upcast JSON to be kept in Raw", name)
+		}
+		record.Raw = nil
+		if !reflect.DeepEqual(record, tc.want) {
+			t.Errorf("%s: expected %+v, got %+v", name, tc.want, record)
+		}
+	}
+}
+
+func TestSchemaVersionsRejectsUnsupported(t *testing.T) {
+	incomplete := NewSchemaVersions(3)
+	incomplete.Register(2, func(map[string]interface{}) error { return nil })
+
+	cases := map[string]struct {
+		schemas *SchemaVersions
+		version int
+	}{
+		"newer than receiver":  {testSchemaVersions(), 4},
+		"negative":             {testSchemaVersions(), -1},
+		"missing upcaster":     {incomplete, 1},
+		"nil rejects upgrades": {nil, CurrentSchemaVersion + 1},
+	}
+
+	for name, tc := range cases {
+		_, err := tc.schemas.DecodeRecord([]byte(`{"requestId":"r1"}`), tc.version)
+
+		if !errors.Is(err, ErrUnsupportedSchemaVersion) {
+			t.Errorf("%s: expected ErrUnsupportedSchemaVersion, got %v", name, err)
+		}
+	}
+}
+
+func TestJSONParserUpcastsEnvelope(t *testing.T) {
+	// Arrange
+	parser := JSONParser{Schemas: testSchemaVersions()}
+	body := `{"eventType":"analytics_batch","schemaVersion":1,"records":[` +
+		`{"requestId":"r1","score":0.5,"matches":3},` +
+		`{"requestId":"r2","schemaVersion":3,"matchScore":70,"vectorMatches":1}]}`
+
+	// Act
+	payload, err := parser.Parse([]byte(body), http.Header{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if payload.SchemaVersion != 1 || len(payload.Records) != 2 {
+		t.Fatalf("Expected a v1 payload with 2 records, got %+v", payload)
+	}
+	if r := payload.Records[0]; r.MatchScore != 50 || r.VectorMatches != 3 || r.SchemaVersion != 1 {
+		t.Errorf("Expected first record upcast from v1, got %+v", r)
+	}
+	if r := payload.Records[1]; r.MatchScore != 70 || r.SchemaVersion != 3 {
+		t.Errorf("Expected second record kept at v3, got %+v", r)
+	}
+}
+
+func TestCloudEventsSchemaVersionExtension(t *testing.T) {
+	// Arrange
+	parser := CloudEventsParser{Schemas: testSchemaVersions()}
+	headers := http.Header{}
+	headers.Set(CloudEventsSpecVersionHeader, "1.0")
+	headers.Set(CloudEventsIDHeader, "evt-1")
+	headers.Set(CloudEventsSourceHeader, "/chatbot")
+	headers.Set(CloudEventsTypeHeader, EventTypeCreated)
+	headers.Set(CloudEventsSchemaVersionHeader, "2")
+
+	// Act
+	payload, err := parser.Parse([]byte(`{"requestId":"r1","matchScore":40,"matches":6}`), headers)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if payload.Data.VectorMatches != 6 || payload.Data.SchemaVersion != 2 {
+		t.Errorf("Expected record upcast from v2, got %+v", payload.Data)
+	}
+
+	headers.Set(CloudEventsSchemaVersionHeader, "two")
+	if _, err := parser.Parse([]byte(`{"requestId":"r1"}`), headers); err == nil || !strings.Contains(err.Error(), "schemaversion") {
+		t.Errorf("Expected error for non-numeric schemaversion, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"crypto"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Built-in signature scheme names
+const (
+	SchemeHMAC             = "hmac"
+	SchemeStandardWebhooks = "standard-webhooks"
+	SchemeGitHub           = "github"
+	SchemeStripe           = "stripe"
+	SchemeSlack            = "slack"
+	SchemeEd25519          = "ed25519"
+	SchemeJWT              = "jwt"
+)
+
+// SchemeOptions carries the settings shared by all signature schemes
+type SchemeOptions struct {
+	Tolerance   time.Duration
+	AllowLegacy bool
+	PublicKeys  Ed25519Keyring
+	JWKS        map[string]crypto.PublicKey
+	JWTPolicy   JWTPolicy
+	Logger      Logger
+}
+
+// SignatureScheme describes how one kind of sender signs its webhooks
+// SignatureHeader lets the transport reject unsigned requests early
+// SignsBody is false for schemes that authenticate from headers alone, which
+// are the only ones usable where the body is streamed rather than buffered
+type SignatureScheme struct {
+	Name            string
+	SignatureHeader string
+	SignsBody       bool
+	NewValidator    func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error)
+}
+
+// SchemeRegistry maps scheme names to their implementations (Open/Closed Principle)
+// New senders are supported by registering a scheme, not by editing the handler
+type SchemeRegistry struct {
+	schemes map[string]SignatureScheme
+}
+
+// NewSchemeRegistry creates an empty registry
+func NewSchemeRegistry() *SchemeRegistry {
+	return &SchemeRegistry{schemes: make(map[string]SignatureScheme)}
+}
+
+// NewDefaultSchemeRegistry creates a registry with all built-in schemes
+func NewDefaultSchemeRegistry() *SchemeRegistry {
+	r := NewSchemeRegistry()
+	r.Register(SignatureScheme{
+		Name:            SchemeHMAC,
+		SignatureHeader: SignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewHMACValidator(keys, opts.Tolerance, opts.AllowLegacy, opts.Logger), nil
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeStandardWebhooks,
+		SignatureHeader: StandardWebhookSignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewStandardWebhooksValidator(keys, opts.Tolerance, opts.Logger)
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeGitHub,
+		SignatureHeader: GitHubSignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewGitHubValidator(keys, opts.Logger), nil
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeStripe,
+		SignatureHeader: StripeSignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewStripeValidator(keys, opts.Tolerance, opts.Logger), nil
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeSlack,
+		SignatureHeader: SlackSignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewSlackValidator(keys, opts.Tolerance, opts.Logger), nil
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeEd25519,
+		SignatureHeader: SignatureHeader,
+		SignsBody:       true,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			if len(opts.PublicKeys) == 0 {
+				return nil, fmt.Errorf("%s scheme requires at least one public key", SchemeEd25519)
+			}
+			return NewEd25519Validator(opts.PublicKeys, opts.Tolerance, opts.Logger), nil
+		},
+	})
+	r.Register(SignatureScheme{
+		Name:            SchemeJWT,
+		SignatureHeader: AuthorizationHeader,
+		NewValidator: func(keys []SigningKey, opts SchemeOptions) (SignatureValidator, error) {
+			return NewJWTValidator(opts.JWKS, opts.JWTPolicy, opts.Logger)
+		},
+	})
+	return r
+}
+
+// Register adds or replaces a scheme
+func (r *SchemeRegistry) Register(scheme SignatureScheme) {
+	r.schemes[scheme.Name] = scheme
+}
+
+// Get looks up a scheme by name
+func (r *SchemeRegistry) Get(name string) (SignatureScheme, error) {
+	scheme, ok := r.schemes[name]
+	if !ok {
+		return SignatureScheme{}, fmt.Errorf("unknown signature scheme %q (available: %v)", name, r.Names())
+	}
+	return scheme, nil
+}
+
+// Names returns the registered scheme names in sorted order
+func (r *SchemeRegistry) Names() []string {
+	names := make([]string, 0, len(r.schemes))
+	for name := range r.schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -5,27 +5,152 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	// SignatureHeader carries the HMAC signature
+	SignatureHeader = "X-Webhook-Signature"
+
+	// TimestampHeader carries the sender timestamp (Unix seconds)
+	TimestampHeader = "X-Webhook-Timestamp"
+
+	// TimestampedSignaturePrefix marks signatures computed over "<timestamp>.<body>"
+	TimestampedSignaturePrefix = "v1="
+
+	// LegacySignaturePrefix marks signatures computed over the body only
+	LegacySignaturePrefix = "sha256="
+
+	// DefaultSignatureTolerance is the replay window applied when none is configured
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+// SigningKey is a named HMAC secret shared with a sender
+// Several keys can be active at once so secrets rotate without downtime
+type SigningKey struct {
+	ID        string
+	Secret    string
+	ExpiresAt time.Time // zero means the key never expires
+}
+
+// activeAt reports whether the key may still be used at t
+func (k SigningKey) activeAt(t time.Time) bool {
+	return k.ExpiresAt.IsZero() || t.Before(k.ExpiresAt)
+}
+
 // HMACValidator implements SignatureValidator using HMAC-SHA256
+// Signatures cover the sender timestamp and the body; requests outside the
+// tolerance window are rejected to prevent replays
 type HMACValidator struct {
-	secret string
+	keys        []SigningKey
+	tolerance   time.Duration
+	allowLegacy bool
+	logger      Logger
+	now         func() time.Time
 }
 
-// NewHMACValidator creates a new HMAC signature validator
-func NewHMACValidator(secret string) *HMACValidator {
-	return &HMACValidator{secret: secret}
+// NewHMACValidator creates a new HMAC signature validator over a set of active keys
+// allowLegacy enables the body-only "sha256=<hex>" format during sender migration
+func NewHMACValidator(keys []SigningKey, tolerance time.Duration, allowLegacy bool, logger Logger) *HMACValidator {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &HMACValidator{
+		keys:        keys,
+		tolerance:   tolerance,
+		allowLegacy: allowLegacy,
+		logger:      logger,
+		now:         time.Now,
+	}
 }
 
 // Validate checks if the payload signature is valid
-func (v *HMACValidator) Validate(payload []byte, signature string) error {
-	mac := hmac.New(sha256.New, []byte(v.secret))
-	mac.Write(payload)
-	expected := hex.EncodeToString(mac.Sum(nil))
+func (v *HMACValidator) Validate(payload []byte, headers Headers) error {
+	signature := headers.Get(SignatureHeader)
+	timestamp := headers.Get(TimestampHeader)
+
+	if strings.HasPrefix(signature, TimestampedSignaturePrefix) {
+		return v.validateTimestamped(payload, strings.TrimPrefix(signature, TimestampedSignaturePrefix), timestamp)
+	}
+
+	if !v.allowLegacy {
+		return fmt.Errorf("%w: legacy body-only signatures are disabled", ErrInvalidSignature)
+	}
+
+	return v.compare(payload, strings.TrimPrefix(signature, LegacySignaturePrefix))
+}
+
+// validateTimestamped enforces the replay window before checking the signature
+func (v *HMACValidator) validateTimestamped(payload []byte, signature string, timestamp string) error {
+	if err := checkTimestamp(timestamp, v.now(), v.tolerance); err != nil {
+		return err
+	}
 
-	if !hmac.Equal([]byte(signature), []byte(expected)) {
-		return fmt.Errorf("invalid signature")
+	return v.compare(timestampedContent(payload, timestamp), signature)
+}
+
+// checkTimestamp parses a Unix-seconds timestamp and rejects it when it is
+// further than tolerance from now in either direction
+func checkTimestamp(timestamp string, now time.Time, tolerance time.Duration) error {
+	if timestamp == "" {
+		return ErrMissingTimestamp
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q is not a Unix timestamp", ErrMissingTimestamp, timestamp)
+	}
+
+	skew := now.Sub(time.Unix(sentAt, 0))
+	if skew > tolerance || skew < -tolerance {
+		return fmt.Errorf("%w: skew %s exceeds %s", ErrTimestampOutOfTolerance, skew.Round(time.Second), tolerance)
+	}
+
+	return nil
+}
+
+// compare checks the signature against every active key
+func (v *HMACValidator) compare(content []byte, signature string) error {
+	matchedID := matchHMAC(v.keys, v.now(), content, []string{signature})
+	if matchedID == "" {
+		return ErrInvalidSignature
 	}
 
+	v.logger.Info("webhook signature matched", "keyId", matchedID)
 	return nil
 }
+
+// matchHMAC returns the ID of the first active key whose hex HMAC-SHA256 of
+// content equals one of the candidates, or "" if none does
+// All keys and candidates are always tried so timing does not reveal which one matched
+func matchHMAC(keys []SigningKey, now time.Time, content []byte, candidates []string) string {
+	matchedID := ""
+
+	for _, key := range keys {
+		if !key.activeAt(now) {
+			continue
+		}
+
+		mac := hmac.New(sha256.New, []byte(key.Secret))
+		mac.Write(content)
+		expected := []byte(hex.EncodeToString(mac.Sum(nil)))
+
+		for _, candidate := range candidates {
+			if hmac.Equal([]byte(candidate), expected) && matchedID == "" {
+				matchedID = key.ID
+			}
+		}
+	}
+
+	return matchedID
+}
+
+// timestampedContent builds the signed content "<timestamp>.<body>"
+func timestampedContent(payload []byte, timestamp string) []byte {
+	content := make([]byte, 0, len(timestamp)+1+len(payload))
+	content = append(content, timestamp...)
+	content = append(content, '.')
+	return append(content, payload...)
+}
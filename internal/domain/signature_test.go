@@ -0,0 +1,157 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret string, content string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hmacHeaders(signature string, timestamp string) http.Header {
+	headers := http.Header{}
+	headers.Set(SignatureHeader, signature)
+	headers.Set(TimestampHeader, timestamp)
+	return headers
+}
+
+// recordingLogger captures info log arguments for assertions
+type recordingLogger struct {
+	InfoArgs [][]interface{}
+}
+
+func (l *recordingLogger) Error(msg string, err error)           {}
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {}
+func (l *recordingLogger) Info(msg string, args ...interface{}) {
+	l.InfoArgs = append(l.InfoArgs, args)
+}
+
+func newTestValidator(allowLegacy bool, now time.Time) *HMACValidator {
+	keys := []SigningKey{{ID: "current", Secret: "test-secret"}}
+	v := NewHMACValidator(keys, 5*time.Minute, allowLegacy, &recordingLogger{})
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func TestHMACValidatorTimestampedSuccess(t *testing.T) {
+	// Arrange
+	now := time.Unix(1700000000, 0)
+	validator := newTestValidator(false, now)
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := TimestampedSignaturePrefix + sign("test-secret", timestamp+"."+string(payload))
+
+	// Act
+	err := validator.Validate(payload, hmacHeaders(signature, timestamp))
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestHMACValidatorTimestampOutOfTolerance(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	validator := newTestValidator(false, now)
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+
+	for name, sentAt := range map[string]time.Time{
+		"stale":        now.Add(-6 * time.Minute),
+		"future-dated": now.Add(6 * time.Minute),
+	} {
+		timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+		signature := TimestampedSignaturePrefix + sign("test-secret", timestamp+"."+string(payload))
+
+		err := validator.Validate(payload, hmacHeaders(signature, timestamp))
+
+		if !errors.Is(err, ErrTimestampOutOfTolerance) {
+			t.Errorf("%s: expected ErrTimestampOutOfTolerance, got %v", name, err)
+		}
+	}
+}
+
+func TestHMACValidatorTimestampTampered(t *testing.T) {
+	// Signature computed for one timestamp must not verify with another
+	now := time.Unix(1700000000, 0)
+	validator := newTestValidator(false, now)
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+	signature := TimestampedSignaturePrefix + sign("test-secret", "1699999990."+string(payload))
+
+	err := validator.Validate(payload, hmacHeaders(signature, "1700000000"))
+
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestHMACValidatorMissingTimestamp(t *testing.T) {
+	validator := newTestValidator(false, time.Unix(1700000000, 0))
+	payload := []byte(`{}`)
+	signature := TimestampedSignaturePrefix + sign("test-secret", "."+string(payload))
+
+	err := validator.Validate(payload, hmacHeaders(signature, ""))
+
+	if !errors.Is(err, ErrMissingTimestamp) {
+		t.Errorf("Expected ErrMissingTimestamp, got %v", err)
+	}
+}
+
+func TestHMACValidatorLegacySignature(t *testing.T) {
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+	signature := LegacySignaturePrefix + sign("test-secret", string(payload))
+
+	// Rejected unless explicitly enabled
+	if err := newTestValidator(false, time.Now()).Validate(payload, hmacHeaders(signature, "")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected legacy signature to be rejected, got %v", err)
+	}
+
+	// Accepted with and without the sha256= prefix when enabled
+	legacy := newTestValidator(true, time.Now())
+	if err := legacy.Validate(payload, hmacHeaders(signature, "")); err != nil {
+		t.Errorf("Expected legacy signature to be accepted, got %v", err)
+	}
+	if err := legacy.Validate(payload, hmacHeaders(sign("test-secret", string(payload)), "")); err != nil {
+		t.Errorf("Expected bare legacy signature to be accepted, got %v", err)
+	}
+}
+
+func TestHMACValidatorKeyRotation(t *testing.T) {
+	// Arrange
+	now := time.Unix(1700000000, 0)
+	logger := &recordingLogger{}
+	keys := []SigningKey{
+		{ID: "2024-11", Secret: "new-secret"},
+		{ID: "2024-10", Secret: "old-secret", ExpiresAt: now.Add(time.Hour)},
+		{ID: "2024-09", Secret: "retired-secret", ExpiresAt: now.Add(-time.Hour)},
+	}
+	validator := NewHMACValidator(keys, 5*time.Minute, false, logger)
+	validator.now = func() time.Time { return now }
+
+	payload := []byte(`{"eventType":"analytics_record_created"}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signedWith := func(secret string) string {
+		return TimestampedSignaturePrefix + sign(secret, timestamp+"."+string(payload))
+	}
+
+	// Act & Assert: previous key still accepted and reported by ID
+	if err := validator.Validate(payload, hmacHeaders(signedWith("old-secret"), timestamp)); err != nil {
+		t.Fatalf("Expected previous key to be accepted, got %v", err)
+	}
+	if len(logger.InfoArgs) != 1 || logger.InfoArgs[0][1] != "2024-10" {
+		t.Errorf("Expected matched key ID 2024-10 to be logged, got %v", logger.InfoArgs)
+	}
+
+	// Expired key rejected
+	if err := validator.Validate(payload, hmacHeaders(signedWith("retired-secret"), timestamp)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected expired key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Standard Webhooks headers (https://www.standardwebhooks.com)
+const (
+	StandardWebhookIDHeader        = "webhook-id"
+	StandardWebhookTimestampHeader = "webhook-timestamp"
+	StandardWebhookSignatureHeader = "webhook-signature"
+
+	// standardWebhookSecretPrefix precedes the base64-encoded secret
+	standardWebhookSecretPrefix = "whsec_"
+
+	// standardWebhookSignatureVersion is the only version defined by the spec
+	standardWebhookSignatureVersion = "v1"
+)
+
+// standardWebhookKey is a SigningKey with its secret decoded from base64
+type standardWebhookKey struct {
+	SigningKey
+	decoded []byte
+}
+
+// StandardWebhooksValidator implements SignatureValidator per the Standard Webhooks spec
+// Signed content is "<webhook-id>.<webhook-timestamp>.<body>", signatures are
+// a space-separated list of "v1,<base64>"
+type StandardWebhooksValidator struct {
+	keys      []standardWebhookKey
+	tolerance time.Duration
+	logger    Logger
+	now       func() time.Time
+}
+
+// NewStandardWebhooksValidator creates a validator from "whsec_<base64>" secrets
+func NewStandardWebhooksValidator(keys []SigningKey, tolerance time.Duration, logger Logger) (*StandardWebhooksValidator, error) {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+
+	decoded := make([]standardWebhookKey, 0, len(keys))
+	for _, key := range keys {
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key.Secret, standardWebhookSecretPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not a base64 whsec_ secret: %w", key.ID, err)
+		}
+		decoded = append(decoded, standardWebhookKey{SigningKey: key, decoded: secret})
+	}
+
+	return &StandardWebhooksValidator{
+		keys:      decoded,
+		tolerance: tolerance,
+		logger:    logger,
+		now:       time.Now,
+	}, nil
+}
+
+// Validate checks the webhook-signature header against every active key
+func (v *StandardWebhooksValidator) Validate(payload []byte, headers Headers) error {
+	msgID := headers.Get(StandardWebhookIDHeader)
+	timestamp := headers.Get(StandardWebhookTimestampHeader)
+	signatures := headers.Get(StandardWebhookSignatureHeader)
+
+	if msgID == "" {
+		return fmt.Errorf("%w: %s", ErrMissingField, StandardWebhookIDHeader)
+	}
+
+	now := v.now()
+	if err := checkTimestamp(timestamp, now, v.tolerance); err != nil {
+		return err
+	}
+
+	content := timestampedContent(payload, msgID+"."+timestamp)
+
+	// Compute every active key's signature and check every candidate,
+	// so timing does not reveal which key or list entry matched
+	matchedID := ""
+	for _, key := range v.keys {
+		if !key.activeAt(now) {
+			continue
+		}
+
+		mac := hmac.New(sha256.New, key.decoded)
+		mac.Write(content)
+		expected := []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+		for _, candidate := range strings.Fields(signatures) {
+			version, sig, ok := strings.Cut(candidate, ",")
+			if !ok || version != standardWebhookSignatureVersion {
+				continue
+			}
+			if hmac.Equal([]byte(sig), expected) && matchedID == "" {
+				matchedID = key.ID
+			}
+		}
+	}
+
+	if matchedID == "" {
+		return ErrInvalidSignature
+	}
+
+	v.logger.Info("webhook signature matched", "keyId", matchedID, "webhookId", msgID)
+	return nil
+}
+
+// MessageID implements MessageIdentifier: webhook-id is part of the signed content
+func (v *StandardWebhooksValidator) MessageID(headers Headers) string {
+	return headers.Get(StandardWebhookIDHeader)
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Reference vector from the Standard Webhooks specification
+const (
+	swSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	swMsgID     = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	swTimestamp = "1614265330"
+	swPayload   = `{"test": 2432232314}`
+	swSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+func standardHeaders(msgID, timestamp, signature string) http.Header {
+	headers := http.Header{}
+	headers.Set(StandardWebhookIDHeader, msgID)
+	headers.Set(StandardWebhookTimestampHeader, timestamp)
+	headers.Set(StandardWebhookSignatureHeader, signature)
+	return headers
+}
+
+func newTestStandardValidator(t *testing.T, keys ...SigningKey) *StandardWebhooksValidator {
+	t.Helper()
+	validator, err := NewStandardWebhooksValidator(keys, 5*time.Minute, &recordingLogger{})
+	if err != nil {
+		t.Fatalf("Expected valid secrets, got %v", err)
+	}
+	validator.now = func() time.Time { return time.Unix(1614265330, 0) }
+	return validator
+}
+
+func TestStandardWebhooksValidatorReferenceVector(t *testing.T) {
+	// Arrange
+	validator := newTestStandardValidator(t, SigningKey{ID: "current", Secret: swSecret})
+
+	// Act: the matching signature may appear anywhere in the list
+	err := validator.Validate([]byte(swPayload), standardHeaders(swMsgID, swTimestamp, "v1,bm90LXRoaXMtb25l "+swSignature))
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestStandardWebhooksValidatorRejectsTamperedContent(t *testing.T) {
+	validator := newTestStandardValidator(t, SigningKey{ID: "current", Secret: swSecret})
+
+	cases := map[string]http.Header{
+		"other message id":  standardHeaders("msg_other", swTimestamp, swSignature),
+		"unknown version":   standardHeaders(swMsgID, swTimestamp, "v2,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="),
+		"missing signature": standardHeaders(swMsgID, swTimestamp, ""),
+	}
+	for name, headers := range cases {
+		if err := validator.Validate([]byte(swPayload), headers); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
+
+func TestStandardWebhooksValidatorTimestampOutOfTolerance(t *testing.T) {
+	validator := newTestStandardValidator(t, SigningKey{ID: "current", Secret: swSecret})
+	validator.now = func() time.Time { return time.Unix(1614265330, 0).Add(time.Hour) }
+
+	err := validator.Validate([]byte(swPayload), standardHeaders(swMsgID, swTimestamp, swSignature))
+
+	if !errors.Is(err, ErrTimestampOutOfTolerance) {
+		t.Errorf("Expected ErrTimestampOutOfTolerance, got %v", err)
+	}
+}
+
+func TestStandardWebhooksValidatorInvalidSecret(t *testing.T) {
+	_, err := NewStandardWebhooksValidator([]SigningKey{{ID: "bad", Secret: "whsec_not base64!"}}, time.Minute, &recordingLogger{})
+
+	if err == nil {
+		t.Errorf("Expected error for non-base64 secret, got nil")
+	}
+}
+
+func TestMessageIDUsesOnlyAuthenticatedIDs(t *testing.T) {
+	// Arrange
+	standard := newTestStandardValidator(t, SigningKey{ID: "current", Secret: swSecret})
+	hmacValidator := NewHMACValidator([]SigningKey{{ID: "k", Secret: "s"}}, time.Minute, false, &recordingLogger{})
+	headers := standardHeaders(swMsgID, swTimestamp, swSignature)
+
+	// Act
+	signed := MessageID([]byte(swPayload), headers, standard)
+	unsigned := MessageID([]byte(swPayload), headers, hmacValidator)
+
+	// Assert
+	if signed != swMsgID {
+		t.Errorf("Expected the signed webhook-id, got %q", signed)
+	}
+	if unsigned == swMsgID || unsigned[:7] != "sha256:" {
+		t.Errorf("Expected a body digest for a scheme that does not sign webhook-id, got %q", unsigned)
+	}
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "crypto"
+
+// Tenant is one sender with its own credentials, limits and storage location
+type Tenant struct {
+	ID               string
+	Scheme           string
+	Keys             []SigningKey
+	RateLimit        float64 // requests per second, 0 for unlimited
+	Burst            int
+	CollectionPrefix string    // prepended to the analytics collection/path
+	Enrichers        Enrichers // nil uses the deployment's ENRICHERS
+
+	// Credentials for the ed25519 and jwt schemes, loaded for this tenant
+	// alone so its keys are never accepted on another tenant's route
+	PublicKeys Ed25519Keyring
+	JWKS       map[string]crypto.PublicKey
+	JWTPolicy  JWTPolicy
+}
+
+// AnalyticsCollection returns the collection or path name for this tenant's records
+// A nil tenant (single-sender deployment) uses base unchanged
+func (t *Tenant) AnalyticsCollection(base string) string {
+	if t == nil {
+		return base
+	}
+	return t.CollectionPrefix + base
+}
@@ -1,7 +1,11 @@
 // Package domain contains domain models and interfaces following SOLID principles
 package domain
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"io"
+)
 
 // AnalyticsRecord represents a complete analytics record from the chatbot
 type AnalyticsRecord struct {
@@ -14,13 +18,42 @@ type AnalyticsRecord struct {
 	SessionID     string `json:"sessionId"`
 	Week          string `json:"week"`
 	Timestamp     int64  `json:"timestamp"`
+
+	// SchemaVersion is the version the record arrived in, before upcasting
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+
+	// Extra holds fields the sender set that are not part of the record, kept
+	// in permissive decoding (see DecodeMode) and stored as "extra". Its
+	// strings are redacted and encrypted like query, since any field may hold PII
+	Extra map[string]interface{} `json:"-"`
+
+	// Raw is the record's JSON as sent (after upcasting), so schema validation
+	// sees missing fields rather than Go zero values. Validators that correct
+	// a field write the correction into it too
+	Raw json.RawMessage `json:"-"`
+
+	// CloudEvent is set by the parser when the record arrived as a CloudEvent
+	CloudEvent *CloudEventAttributes `json:"-"`
+
+	// Derived fields, set by enrichers (see Enricher) and never by the sender
+	QueryNormalized  string `json:"-"` // lowercased NFC query
+	ScoreBand        string `json:"-"` // matchScore band, e.g. "high"
+	ReceivedAt       int64  `json:"-"` // Unix seconds
+	ReceivedAtMillis int64  `json:"-"` // Unix milliseconds
+
+	// Redactions names the redaction rules that fired on query or reasoning
+	Redactions []string `json:"-"`
 }
 
 // WebhookPayload represents the incoming webhook payload from AWS Lambda
+// Batch events (EventTypeBatch) carry their records in Records instead of Data
+// SchemaVersion is the sender's schema (see SchemaVersions); zero means 1
 type WebhookPayload struct {
-	EventType string          `json:"eventType"`
-	Timestamp int64           `json:"timestamp"`
-	Data      AnalyticsRecord `json:"data"`
+	EventType     string            `json:"eventType"`
+	Timestamp     int64             `json:"timestamp"`
+	SchemaVersion int               `json:"schemaVersion,omitempty"`
+	Data          AnalyticsRecord   `json:"data"`
+	Records       []AnalyticsRecord `json:"records,omitempty"`
 }
 
 // AnalyticsWriter interface (Dependency Inversion Principle)
@@ -29,10 +62,54 @@ type AnalyticsWriter interface {
 	Write(ctx context.Context, record AnalyticsRecord) error
 }
 
+// RetryJob is a write that failed on one sink, to be run again later
+// Context keeps the failed request's values, such as the tenant that decides
+// where the record is stored, without its cancellation; Run is called with a
+// context derived from it
+type RetryJob struct {
+	Sink      string
+	Operation string // "write", "writeBatch", "update", "delete" or "tombstone"
+	Context   context.Context
+	Run       func(ctx context.Context) error
+}
+
+// RetryQueue interface (Dependency Inversion Principle)
+// Takes failed best-effort writes so they are retried off the request path
+type RetryQueue interface {
+	Enqueue(job RetryJob) error
+}
+
+// Headers gives validators read access to transport headers
+// Satisfied by http.Header without coupling the domain to net/http
+type Headers interface {
+	Get(key string) string
+}
+
+// NonceStore interface (Dependency Inversion Principle)
+// Remembers delivered message IDs so replays inside the tolerance window are ignored
+type NonceStore interface {
+	// Reserve records id and reports false if it was already recorded and not expired
+	Reserve(ctx context.Context, id string) (bool, error)
+	// Release forgets id so a failed delivery can be retried by the sender
+	Release(ctx context.Context, id string) error
+}
+
+// BodyDecoder interface (Dependency Inversion Principle)
+// Turns the signed wire bytes (e.g. compressed) into the bytes that are parsed
+type BodyDecoder interface {
+	Decode(body []byte, headers Headers) ([]byte, error)
+}
+
+// StreamDecoder interface (Dependency Inversion Principle)
+// Like BodyDecoder, for bodies that are read as a stream rather than buffered
+type StreamDecoder interface {
+	DecodeStream(body io.Reader, headers Headers) (io.ReadCloser, error)
+}
+
 // SignatureValidator interface (Dependency Inversion Principle)
 // Separates validation logic from transport layer
 type SignatureValidator interface {
-	Validate(payload []byte, signature string) error
+	Validate(payload []byte, headers Headers) error
 }
 
 // Logger interface (Dependency Inversion Principle)
@@ -46,5 +123,5 @@ type Logger interface {
 // WebhookProcessor interface (Dependency Inversion Principle)
 // Main business logic abstraction
 type WebhookProcessor interface {
-	Process(ctx context.Context, payload []byte, signature string) error
+	Process(ctx context.Context, payload []byte, headers Headers) (*ProcessResult, error)
 }
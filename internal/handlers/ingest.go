@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// NDJSONContentType is the media type accepted by the ingest endpoint
+const NDJSONContentType = "application/x-ndjson"
+
+// IngestHandler streams NDJSON bulk loads into a domain.StreamIngester
+type IngestHandler struct {
+	ingester domain.StreamIngester
+	logger   domain.Logger
+}
+
+// NewIngestHandler creates a new ingest handler
+func NewIngestHandler(ingester domain.StreamIngester, logger domain.Logger) *IngestHandler {
+	return &IngestHandler{
+		ingester: ingester,
+		logger:   logger,
+	}
+}
+
+// ingestResponse is the body returned for every ingest that got past authentication
+type ingestResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	*domain.IngestSummary
+}
+
+// ServeHTTP passes the request body to the ingester without reading it first
+func (h *IngestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != NDJSONContentType {
+		http.Error(w, "Content-Type must be "+NDJSONContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	defer r.Body.Close()
+
+	summary, err := h.ingester.Ingest(r.Context(), r.Body, r.Header)
+	if summary == nil {
+		h.logger.Error("failed to authenticate ingest", err)
+		http.Error(w, "Failed to process ingest", http.StatusUnauthorized)
+		return
+	}
+
+	// Stored chunks stay stored on failure; the summary says how far the load got
+	status := http.StatusOK
+	response := ingestResponse{Success: err == nil, IngestSummary: summary}
+	if err != nil {
+		h.logger.Error("ingest stopped early", err)
+		response.Error = err.Error()
+		status = http.StatusInternalServerError
+		switch {
+		case errors.Is(err, domain.ErrInvalidPayload), errors.Is(err, domain.ErrMalformedEncoding):
+			status = http.StatusBadRequest
+		case errors.Is(err, domain.ErrUnsupportedEncoding):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, domain.ErrDecodedTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("failed to write ingest response", err)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// MockStreamIngester for testing
+type MockStreamIngester struct {
+	Body    string
+	Summary *domain.IngestSummary
+	Error   error
+}
+
+func (m *MockStreamIngester) Ingest(ctx context.Context, body io.Reader, headers domain.Headers) (*domain.IngestSummary, error) {
+	data, _ := io.ReadAll(body)
+	m.Body = string(data)
+	return m.Summary, m.Error
+}
+
+func TestIngestHandlerServeHTTPSummary(t *testing.T) {
+	// Arrange
+	summary := &domain.IngestSummary{Lines: 2, Accepted: 1}
+	summary.Reject(2, "req_2", &domain.ValidationError{Violations: []domain.Violation{
+		{Pointer: "/query", Rule: "minLength", Message: "length must be >= 1, but got 0"},
+	}})
+	ingester := &MockStreamIngester{Summary: summary}
+	handler := NewIngestHandler(ingester, &MockHandlerLogger{})
+
+	req := httptest.NewRequest("POST", "/ingest", strings.NewReader("line1\nline2\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if ingester.Body != "line1\nline2\n" {
+		t.Errorf("Expected body to reach the ingester, got %q", ingester.Body)
+	}
+
+	var response ingestResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if !response.Success || response.IngestSummary == nil || response.Rejected != 1 || response.Errors[0].Line != 2 {
+		t.Fatalf("Expected summary with rejected line 2, got %s", w.Body.String())
+	}
+	if v := response.Errors[0].Violations; len(v) != 1 || v[0].Pointer != "/query" || v[0].Rule != "minLength" {
+		t.Errorf("Expected the line's schema violation, got %s", w.Body.String())
+	}
+}
+
+func TestIngestHandlerServeHTTPStatuses(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		ingester    *MockStreamIngester
+		want        int
+	}{
+		"wrong content type": {"application/json", &MockStreamIngester{}, http.StatusUnsupportedMediaType},
+		"unauthenticated":    {NDJSONContentType, &MockStreamIngester{Error: domain.ErrInvalidToken}, http.StatusUnauthorized},
+		"malformed stream":   {NDJSONContentType, &MockStreamIngester{Summary: &domain.IngestSummary{}, Error: domain.ErrInvalidPayload}, http.StatusBadRequest},
+		"unknown encoding":   {NDJSONContentType, &MockStreamIngester{Summary: &domain.IngestSummary{}, Error: domain.ErrUnsupportedEncoding}, http.StatusUnsupportedMediaType},
+		"oversized gzip":     {NDJSONContentType, &MockStreamIngester{Summary: &domain.IngestSummary{}, Error: domain.ErrDecodedTooLarge}, http.StatusRequestEntityTooLarge},
+		"write failure":      {NDJSONContentType, &MockStreamIngester{Summary: &domain.IngestSummary{}, Error: domain.ErrDatabaseWrite}, http.StatusInternalServerError},
+	}
+
+	for name, tc := range cases {
+		handler := NewIngestHandler(tc.ingester, &MockHandlerLogger{})
+		req := httptest.NewRequest("POST", "/ingest", strings.NewReader("{}\n"))
+		req.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+	}
+}
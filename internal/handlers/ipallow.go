@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// IPAllowlistMiddleware rejects requests whose client IP is outside the
+// allowed CIDRs, before the body is read
+type IPAllowlistMiddleware struct {
+	next            http.Handler
+	allowlist       []netip.Prefix
+	trustedHops     int
+	forwardedHeader string
+	logger          domain.Logger
+	rejected        atomic.Uint64
+}
+
+// NewIPAllowlistMiddleware creates the middleware
+// trustedHops is the number of reverse proxies in front of the server (e.g. 1
+// behind Cloud Run) and forwardedHeader the header they append the client to
+// ("X-Forwarded-For" or "Forwarded"); it is only trusted that far back
+func NewIPAllowlistMiddleware(next http.Handler, allowlist []netip.Prefix, trustedHops int, forwardedHeader string, logger domain.Logger) *IPAllowlistMiddleware {
+	return &IPAllowlistMiddleware{
+		next:            next,
+		allowlist:       allowlist,
+		trustedHops:     trustedHops,
+		forwardedHeader: forwardedHeader,
+		logger:          logger,
+	}
+}
+
+// ServeHTTP resolves the client IP and enforces the allowlist
+func (m *IPAllowlistMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, ok := ClientIP(r, m.trustedHops, m.forwardedHeader)
+	if !ok || !m.allowed(ip) {
+		count := m.rejected.Add(1)
+		m.logger.Info("request rejected by IP allowlist", "ip", ip, "remoteAddr", r.RemoteAddr, "rejected", count)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	m.next.ServeHTTP(w, r)
+}
+
+// Rejected returns how many requests the allowlist has rejected
+func (m *IPAllowlistMiddleware) Rejected() uint64 {
+	return m.rejected.Load()
+}
+
+// allowed reports whether ip falls inside any allowed prefix
+func (m *IPAllowlistMiddleware) allowed(ip netip.Addr) bool {
+	for _, prefix := range m.allowlist {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP resolves the sender's address from RemoteAddr and forwardedHeader,
+// the one header our proxies write. The hop chain is that header's entries
+// followed by RemoteAddr; the last trustedHops entries are our own proxies, so
+// the client is the entry just before them. Entries further left are
+// sender-controlled and ignored, and a chain too short to reach past our
+// proxies is rejected rather than trusting one of them
+func ClientIP(r *http.Request, trustedHops int, forwardedHeader string) (netip.Addr, bool) {
+	chain := forwardedChain(r.Header, forwardedHeader)
+	chain = append(chain, r.RemoteAddr)
+
+	index := len(chain) - 1 - trustedHops
+	if index < 0 {
+		return netip.Addr{}, false
+	}
+	return parseHop(chain[index])
+}
+
+// forwardedChain lists the client addresses in name, parsed as RFC 7239 when
+// it is the Forwarded header and as a comma-separated list otherwise
+// Other forwarding headers are ignored, since a sender can set any of them
+func forwardedChain(header http.Header, name string) []string {
+	if name == "" {
+		return nil
+	}
+
+	var chain []string
+	if strings.EqualFold(name, "Forwarded") {
+		for _, value := range header.Values(name) {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						chain = append(chain, strings.Trim(val, `"`))
+					}
+				}
+			}
+		}
+		return chain
+	}
+
+	for _, value := range header.Values(name) {
+		for _, hop := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(hop))
+		}
+	}
+	return chain
+}
+
+// parseHop parses "ip", "ip:port", "[ipv6]" or "[ipv6]:port"
+func parseHop(hop string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
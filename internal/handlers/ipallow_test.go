@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	cases := map[string]struct {
+		remoteAddr  string
+		headers     map[string]string
+		trustedHops int
+		header      string
+		want        string
+	}{
+		"no proxy ignores spoofed header": {
+			remoteAddr: "203.0.113.7:4711",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			want:       "203.0.113.7",
+		},
+		"one trusted hop": {
+			remoteAddr:  "169.254.1.1:443",
+			headers:     map[string]string{"X-Forwarded-For": "10.0.0.1, 198.51.100.4"},
+			trustedHops: 1,
+			header:      "X-Forwarded-For",
+			want:        "198.51.100.4",
+		},
+		"spoofed forwarded header ignored when proxy writes xff": {
+			remoteAddr:  "169.254.1.1:443",
+			headers:     map[string]string{"Forwarded": "for=198.51.100.4", "X-Forwarded-For": "203.0.113.9"},
+			trustedHops: 1,
+			header:      "X-Forwarded-For",
+			want:        "203.0.113.9",
+		},
+		"forwarded header with ipv6": {
+			remoteAddr:  "169.254.1.1:443",
+			headers:     map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`},
+			trustedHops: 1,
+			header:      "Forwarded",
+			want:        "2001:db8::1",
+		},
+	}
+
+	for name, tc := range cases {
+		req := httptest.NewRequest("POST", "/webhook", nil)
+		req.RemoteAddr = tc.remoteAddr
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+
+		ip, ok := ClientIP(req, tc.trustedHops, tc.header)
+
+		if !ok || ip.String() != tc.want {
+			t.Errorf("%s: expected %s, got %s (ok=%v)", name, tc.want, ip, ok)
+		}
+	}
+}
+
+func TestClientIPRejectsShortChain(t *testing.T) {
+	// Arrange: two proxies are configured, but only one hop was recorded
+	req := httptest.NewRequest("POST", "/webhook", nil)
+	req.RemoteAddr = "169.254.1.1:443"
+	req.Header.Set("X-Forwarded-For", "198.51.100.4")
+
+	// Act
+	_, ok := ClientIP(req, 2, "X-Forwarded-For")
+
+	// Assert: the leftmost entry is sender-controlled and must not be used
+	if ok {
+		t.Errorf("Expected a chain shorter than the trusted hops to be rejected")
+	}
+}
+
+func TestIPAllowlistMiddleware(t *testing.T) {
+	// Arrange
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	allowlist := []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")}
+	logger := &MockHandlerLogger{}
+	middleware := NewIPAllowlistMiddleware(next, allowlist, 1, "X-Forwarded-For", logger)
+
+	allowed := httptest.NewRequest("POST", "/webhook", nil)
+	allowed.RemoteAddr = "169.254.1.1:443"
+	allowed.Header.Set("X-Forwarded-For", "198.51.100.4")
+
+	denied := httptest.NewRequest("POST", "/webhook", nil)
+	denied.RemoteAddr = "169.254.1.1:443"
+	denied.Header.Set("X-Forwarded-For", "198.51.100.4, 203.0.113.9")
+
+	// Act & Assert
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, allowed)
+	if !called || w.Code != http.StatusOK {
+		t.Errorf("Expected allowlisted request to pass, got status %d", w.Code)
+	}
+
+	called = false
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, denied)
+	if called || w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+	if middleware.Rejected() != 1 || len(logger.InfoLogs) != 1 {
+		t.Errorf("Expected 1 counted and logged rejection, got %d/%d", middleware.Rejected(), len(logger.InfoLogs))
+	}
+}
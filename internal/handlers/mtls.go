@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// NewMutualTLSConfig creates a TLS config that requires client certificates
+// signed by one of the CAs in caFile (PEM bundle)
+func NewMutualTLSConfig(caFile string) (*tls.Config, error) {
+	pemData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", caFile)
+	}
+
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// ClientCertMiddleware admits only requests whose verified client certificate
+// matches the allowlist, and records the matched identity in the request context
+type ClientCertMiddleware struct {
+	next      http.Handler
+	allowlist map[string]bool
+	logger    domain.Logger
+}
+
+// NewClientCertMiddleware creates the middleware
+// allowlist entries are compared with the subject common name and every DNS,
+// URI and email SAN; an empty allowlist admits any certificate the CA verified
+func NewClientCertMiddleware(next http.Handler, allowlist []string, logger domain.Logger) *ClientCertMiddleware {
+	allowed := make(map[string]bool, len(allowlist))
+	for _, identity := range allowlist {
+		allowed[identity] = true
+	}
+	return &ClientCertMiddleware{
+		next:      next,
+		allowlist: allowed,
+		logger:    logger,
+	}
+}
+
+// ServeHTTP checks the client certificate before passing the request on
+func (m *ClientCertMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		m.logger.Info("request without verified client certificate", "remoteAddr", r.RemoteAddr)
+		http.Error(w, "Client certificate required", http.StatusUnauthorized)
+		return
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	identity, ok := m.match(leaf)
+	if !ok {
+		m.logger.Info("client certificate not in allowlist", "subject", leaf.Subject.String())
+		http.Error(w, "Client certificate not allowed", http.StatusForbidden)
+		return
+	}
+
+	m.next.ServeHTTP(w, r.WithContext(domain.WithSender(r.Context(), identity)))
+}
+
+// match returns the first certificate identity found in the allowlist
+func (m *ClientCertMiddleware) match(cert *x509.Certificate) (string, bool) {
+	identities := []string{cert.Subject.CommonName}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+
+	if len(m.allowlist) == 0 {
+		return cert.Subject.String(), true
+	}
+	for _, identity := range identities {
+		if identity != "" && m.allowlist[identity] {
+			return identity, true
+		}
+	}
+	return "", false
+}
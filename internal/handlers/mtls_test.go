@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// senderRecorder captures the sender identity the middleware passes on
+type senderRecorder struct {
+	Called bool
+	Sender string
+}
+
+func (s *senderRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Called = true
+	s.Sender = domain.SenderFromContext(r.Context())
+}
+
+func requestWithClientCert(cert *x509.Certificate) *http.Request {
+	req := httptest.NewRequest("POST", "/webhook", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return req
+}
+
+func TestClientCertMiddlewareAllowedSAN(t *testing.T) {
+	// Arrange
+	next := &senderRecorder{}
+	middleware := NewClientCertMiddleware(next, []string{"lambda.cv-analytics.internal"}, &MockHandlerLogger{})
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "cv-analytics-processor"},
+		DNSNames: []string{"lambda.cv-analytics.internal"},
+	}
+	w := httptest.NewRecorder()
+
+	// Act
+	middleware.ServeHTTP(w, requestWithClientCert(cert))
+
+	// Assert
+	if !next.Called {
+		t.Fatalf("Expected request to reach the handler, got status %d", w.Code)
+	}
+	if next.Sender != "lambda.cv-analytics.internal" {
+		t.Errorf("Expected sender lambda.cv-analytics.internal, got %q", next.Sender)
+	}
+}
+
+func TestClientCertMiddlewareRejectsUnlisted(t *testing.T) {
+	next := &senderRecorder{}
+	middleware := NewClientCertMiddleware(next, []string{"cv-analytics-processor"}, &MockHandlerLogger{})
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "someone-else"}}
+	w := httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, requestWithClientCert(cert))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+	if next.Called {
+		t.Errorf("Handler should not be called for unlisted certificate")
+	}
+}
+
+func TestClientCertMiddlewareRequiresCertificate(t *testing.T) {
+	next := &senderRecorder{}
+	middleware := NewClientCertMiddleware(next, nil, &MockHandlerLogger{})
+	w := httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// SenderHeader names the tenant when it is not given as a path segment
+const SenderHeader = "X-Webhook-Sender"
+
+// TenantPathValue is the ServeMux wildcard holding the tenant ID,
+// e.g. "/tenants/{tenant}"
+const TenantPathValue = "tenant"
+
+// TenantRoute pairs a tenant with the handler built from its credentials
+type TenantRoute struct {
+	Tenant  *domain.Tenant
+	Handler http.Handler
+}
+
+// TenantRouter dispatches each request to its tenant's handler and records
+// the tenant in the request context for the service and writer
+type TenantRouter struct {
+	routes map[string]TenantRoute
+	logger domain.Logger
+}
+
+// NewTenantRouter creates a router over the given tenant routes
+func NewTenantRouter(routes []TenantRoute, logger domain.Logger) *TenantRouter {
+	byID := make(map[string]TenantRoute, len(routes))
+	for _, route := range routes {
+		byID[route.Tenant.ID] = route
+	}
+	return &TenantRouter{routes: byID, logger: logger}
+}
+
+// ServeHTTP resolves the tenant from the path segment or X-Webhook-Sender header
+func (t *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.PathValue(TenantPathValue)
+	if tenantID == "" {
+		tenantID = r.Header.Get(SenderHeader)
+	}
+
+	route, ok := t.routes[tenantID]
+	if !ok {
+		t.logger.Info("unknown webhook sender", "tenant", tenantID)
+		http.Error(w, "Unknown sender", http.StatusNotFound)
+		return
+	}
+
+	route.Handler.ServeHTTP(w, r.WithContext(domain.WithTenant(r.Context(), route.Tenant)))
+}
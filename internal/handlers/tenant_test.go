@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// tenantRecorder captures the tenant the router passes on
+type tenantRecorder struct {
+	Tenant *domain.Tenant
+}
+
+func (s *tenantRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Tenant = domain.TenantFromContext(r.Context())
+}
+
+func newTestTenantMux(recorder http.Handler) *http.ServeMux {
+	router := NewTenantRouter([]TenantRoute{
+		{Tenant: &domain.Tenant{ID: "portfolio"}, Handler: recorder},
+	}, &MockHandlerLogger{})
+
+	mux := http.NewServeMux()
+	mux.Handle("/tenants/{"+TenantPathValue+"}", router)
+	mux.Handle("/tenants", router)
+	return mux
+}
+
+func TestTenantRouterResolvesTenant(t *testing.T) {
+	cases := map[string]func(*http.Request){
+		"/tenants/portfolio": nil,
+		"/tenants": func(r *http.Request) {
+			r.Header.Set(SenderHeader, "portfolio")
+		},
+	}
+
+	for path, prepare := range cases {
+		// Arrange
+		recorder := &tenantRecorder{}
+		mux := newTestTenantMux(recorder)
+		req := httptest.NewRequest("POST", path, nil)
+		if prepare != nil {
+			prepare(req)
+		}
+		w := httptest.NewRecorder()
+
+		// Act
+		mux.ServeHTTP(w, req)
+
+		// Assert
+		if recorder.Tenant == nil || recorder.Tenant.ID != "portfolio" {
+			t.Errorf("%s: expected tenant portfolio in context, got %+v", path, recorder.Tenant)
+		}
+	}
+}
+
+func TestTenantRouterUnknownTenant(t *testing.T) {
+	// Arrange
+	recorder := &tenantRecorder{}
+	mux := newTestTenantMux(recorder)
+	req := httptest.NewRequest("POST", "/tenants/unknown", nil)
+	w := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if recorder.Tenant != nil {
+		t.Errorf("Expected handler not to be called")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	// Arrange: a burst of 2 with a negligible refill rate
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	middleware := NewRateLimitMiddleware(next, 0.001, 2, &MockHandlerLogger{})
+
+	// Act
+	codes := make([]int, 3)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, httptest.NewRequest("POST", "/webhook", nil))
+		codes[i] = w.Code
+	}
+
+	// Assert
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Errorf("Expected burst of 2 to pass, got %v", codes)
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429 after burst, got %d", codes[2])
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 
@@ -9,15 +11,18 @@ import (
 
 // WebhookHandler handles incoming webhook requests (HTTP transport layer)
 type WebhookHandler struct {
-	processor domain.WebhookProcessor
-	logger    domain.Logger
+	processor       domain.WebhookProcessor
+	logger          domain.Logger
+	signatureHeader string
 }
 
 // NewWebhookHandler creates a new webhook handler
-func NewWebhookHandler(processor domain.WebhookProcessor, logger domain.Logger) *WebhookHandler {
+// signatureHeader is the header the configured validator reads the signature from
+func NewWebhookHandler(processor domain.WebhookProcessor, logger domain.Logger, signatureHeader string) *WebhookHandler {
 	return &WebhookHandler{
-		processor: processor,
-		logger:    logger,
+		processor:       processor,
+		logger:          logger,
+		signatureHeader: signatureHeader,
 	}
 }
 
@@ -38,23 +43,142 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract signature from headers
-	signature := r.Header.Get("X-Webhook-Signature")
-	if signature == "" {
-		h.logger.Info("missing webhook signature header")
-		http.Error(w, "Missing X-Webhook-Signature header", http.StatusBadRequest)
+	// Reject unsigned requests before any processing
+	if r.Header.Get(h.signatureHeader) == "" {
+		h.logger.Info("missing webhook signature header", "header", h.signatureHeader)
+		http.Error(w, "Missing "+h.signatureHeader+" header", http.StatusBadRequest)
 		return
 	}
 
-	// Process webhook
-	if err := h.processor.Process(r.Context(), body, signature); err != nil {
+	// Process webhook; the validator reads whichever headers its scheme needs
+	result, err := h.processor.Process(r.Context(), body, r.Header)
+
+	// Duplicate deliveries are acknowledged so the sender stops retrying
+	if errors.Is(err, domain.ErrDuplicateDelivery) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"success":true,"status":"duplicate"}`))
+		return
+	}
+
+	if errors.Is(err, domain.ErrBatchTooLarge) {
+		h.logger.Error("rejected oversized batch", err)
+		http.Error(w, "Batch too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	switch {
+	case errors.Is(err, domain.ErrUnknownEventType):
+		h.logger.Error("rejected unknown event type", err)
+		http.Error(w, "Unknown eventType", http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, domain.ErrUnsupportedSchemaVersion):
+		h.logger.Error("rejected unsupported schema version", err)
+		http.Error(w, "Unsupported schemaVersion", http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, domain.ErrRecordNotFound):
+		h.logger.Error("event targets a missing record", err)
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrDatabaseWrite):
+		// A storage outage is not the sender's fault, so ask it to retry
+		h.logger.Error("failed to store webhook", err)
+		http.Error(w, "Storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Compressed bodies are only decoded after the signature passes, so these
+	// errors come from authenticated senders and can name the problem
+	switch {
+	case errors.Is(err, domain.ErrDecodedTooLarge):
+		h.logger.Error("rejected oversized compressed body", err)
+		http.Error(w, "Decompressed body too large", http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, domain.ErrUnsupportedEncoding):
+		h.logger.Error("rejected unsupported content encoding", err)
+		http.Error(w, "Unsupported Content-Encoding (use gzip, deflate or zstd)", http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, domain.ErrMalformedEncoding):
+		h.logger.Error("rejected malformed compressed body", err)
+		http.Error(w, "Malformed compressed body", http.StatusBadRequest)
+		return
+	}
+
+	// Schema violations are listed field by field so the sender can fix the payload
+	var validation *domain.ValidationError
+	if errors.As(err, &validation) {
+		h.logger.Error("rejected invalid analytics record", err)
+		h.writeValidationResponse(w, validation)
+		return
+	}
+
+	if err != nil {
 		h.logger.Error("failed to process webhook", err)
 		http.Error(w, "Failed to process webhook", http.StatusUnauthorized)
 		return
 	}
 
+	// Batches report each record so the sender can retry only the failures
+	if result != nil && result.Records != nil {
+		h.writeBatchResponse(w, result)
+		return
+	}
+
 	// Success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"success":true,"status":"ok"}`))
 }
+
+// validationResponse is the body returned when a record fails the schema
+type validationResponse struct {
+	Success    bool               `json:"success"`
+	Error      string             `json:"error"`
+	Violations []domain.Violation `json:"violations"`
+}
+
+// writeValidationResponse writes every violation with 422
+func (h *WebhookHandler) writeValidationResponse(w http.ResponseWriter, validation *domain.ValidationError) {
+	response := validationResponse{
+		Error:      "Payload failed schema validation",
+		Violations: validation.Violations,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("failed to write validation response", err)
+	}
+}
+
+// batchResponse is the body returned for batch deliveries
+type batchResponse struct {
+	Success   bool                   `json:"success"`
+	Status    string                 `json:"status"`
+	Accepted  int                    `json:"accepted"`
+	Duplicate int                    `json:"duplicate"`
+	Invalid   int                    `json:"invalid"`
+	Results   []domain.RecordOutcome `json:"results"`
+}
+
+// writeBatchResponse writes per-record outcomes; status is "partial" when
+// any record was rejected as invalid
+func (h *WebhookHandler) writeBatchResponse(w http.ResponseWriter, result *domain.ProcessResult) {
+	response := batchResponse{
+		Success:   true,
+		Status:    "ok",
+		Accepted:  result.Count(domain.RecordAccepted),
+		Duplicate: result.Count(domain.RecordDuplicate),
+		Invalid:   result.Count(domain.RecordInvalid),
+		Results:   result.Records,
+	}
+	if response.Invalid > 0 {
+		response.Status = "partial"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("failed to write batch response", err)
+	}
+}
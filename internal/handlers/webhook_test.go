@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"example.com/webhook-receiver/internal/domain"
@@ -15,14 +17,15 @@ import (
 type MockWebhookProcessor struct {
 	ProcessCalled bool
 	ProcessError  error
+	Result        *domain.ProcessResult
 }
 
-func (m *MockWebhookProcessor) Process(ctx context.Context, payload []byte, signature string) error {
+func (m *MockWebhookProcessor) Process(ctx context.Context, payload []byte, headers domain.Headers) (*domain.ProcessResult, error) {
 	m.ProcessCalled = true
 	if m.ProcessError != nil {
-		return m.ProcessError
+		return nil, m.ProcessError
 	}
-	return nil
+	return m.Result, nil
 }
 
 // MockHandlerLogger for testing
@@ -48,7 +51,7 @@ func TestWebhookHandlerServeHTTPSuccess(t *testing.T) {
 	// Arrange
 	processor := &MockWebhookProcessor{}
 	logger := &MockHandlerLogger{}
-	handler := NewWebhookHandler(processor, logger)
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
 
 	payload := domain.WebhookPayload{
 		EventType: "analytics_event",
@@ -88,7 +91,7 @@ func TestWebhookHandlerServeHTTPMissingSignature(t *testing.T) {
 	// Arrange
 	processor := &MockWebhookProcessor{}
 	logger := &MockHandlerLogger{}
-	handler := NewWebhookHandler(processor, logger)
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
 
 	payload := domain.WebhookPayload{
 		EventType: "analytics_event",
@@ -122,7 +125,7 @@ func TestWebhookHandlerServeHTTPWrongMethod(t *testing.T) {
 	// Arrange
 	processor := &MockWebhookProcessor{}
 	logger := &MockHandlerLogger{}
-	handler := NewWebhookHandler(processor, logger)
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
 
 	req := httptest.NewRequest("GET", "/webhook", nil)
 	w := httptest.NewRecorder()
@@ -145,7 +148,7 @@ func TestWebhookHandlerServeHTTPProcessorError(t *testing.T) {
 		ProcessError: domain.ErrInvalidSignature,
 	}
 	logger := &MockHandlerLogger{}
-	handler := NewWebhookHandler(processor, logger)
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
 
 	payload := domain.WebhookPayload{
 		EventType: "analytics_event",
@@ -181,7 +184,7 @@ func TestWebhookHandlerServeHTTPInvalidJSON(t *testing.T) {
 		ProcessError: domain.ErrInvalidPayload,
 	}
 	logger := &MockHandlerLogger{}
-	handler := NewWebhookHandler(processor, logger)
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
 
 	invalidJSON := []byte("{invalid json")
 
@@ -197,3 +200,165 @@ func TestWebhookHandlerServeHTTPInvalidJSON(t *testing.T) {
 		t.Errorf("Expected status 401, got %d", w.Code)
 	}
 }
+
+func TestWebhookHandlerServeHTTPDuplicateDelivery(t *testing.T) {
+	// Arrange
+	processor := &MockWebhookProcessor{
+		ProcessError: domain.ErrDuplicateDelivery,
+	}
+	logger := &MockHandlerLogger{}
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Webhook-Signature", "test_signature")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["status"] != "duplicate" {
+		t.Errorf("Expected status=duplicate in response, got %v", response["status"])
+	}
+}
+
+func TestWebhookHandlerServeHTTPBatchOutcomes(t *testing.T) {
+	// Arrange
+	processor := &MockWebhookProcessor{
+		Result: &domain.ProcessResult{Records: []domain.RecordOutcome{
+			{Index: 0, RequestID: "req_1", Status: domain.RecordAccepted},
+			{Index: 1, RequestID: "req_2", Status: domain.RecordDuplicate},
+			{Index: 2, Status: domain.RecordInvalid, Reason: "requestId is required"},
+		}},
+	}
+	logger := &MockHandlerLogger{}
+	handler := NewWebhookHandler(processor, logger, domain.SignatureHeader)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Webhook-Signature", "test_signature")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response batchResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Status != "partial" || response.Accepted != 1 || response.Duplicate != 1 || response.Invalid != 1 {
+		t.Errorf("Expected partial with 1/1/1 outcomes, got %+v", response)
+	}
+	if len(response.Results) != 3 || response.Results[2].Reason != "requestId is required" {
+		t.Errorf("Expected per-record results with reasons, got %+v", response.Results)
+	}
+}
+
+func TestWebhookHandlerServeHTTPBatchTooLarge(t *testing.T) {
+	// Arrange
+	processor := &MockWebhookProcessor{ProcessError: domain.ErrBatchTooLarge}
+	handler := NewWebhookHandler(processor, &MockHandlerLogger{}, domain.SignatureHeader)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Webhook-Signature", "test_signature")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+}
+
+func TestWebhookHandlerServeHTTPEncodingErrors(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want int
+	}{
+		"decompression bomb":   {domain.ErrDecodedTooLarge, http.StatusRequestEntityTooLarge},
+		"malformed stream":     {domain.ErrMalformedEncoding, http.StatusBadRequest},
+		"unsupported encoding": {domain.ErrUnsupportedEncoding, http.StatusUnsupportedMediaType},
+	}
+
+	for name, tc := range cases {
+		processor := &MockWebhookProcessor{ProcessError: fmt.Errorf("failed to decode webhook body: %w", tc.err)}
+		handler := NewWebhookHandler(processor, &MockHandlerLogger{}, domain.SignatureHeader)
+
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`compressed`)))
+		req.Header.Set("X-Webhook-Signature", "test_signature")
+		req.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestWebhookHandlerServeHTTPEventErrors(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want int
+	}{
+		"unknown event type": {domain.ErrUnknownEventType, http.StatusUnprocessableEntity},
+		"missing record":     {domain.ErrRecordNotFound, http.StatusNotFound},
+		"newer schema":       {domain.ErrUnsupportedSchemaVersion, http.StatusUnprocessableEntity},
+		"storage failure":    {domain.ErrDatabaseWrite, http.StatusServiceUnavailable},
+	}
+
+	for name, tc := range cases {
+		processor := &MockWebhookProcessor{ProcessError: fmt.Errorf("wrapped: %w", tc.err)}
+		handler := NewWebhookHandler(processor, &MockHandlerLogger{}, domain.SignatureHeader)
+
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`{}`)))
+		req.Header.Set("X-Webhook-Signature", "test_signature")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tc.want {
+			t.Errorf("%s: expected status %d, got %d", name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestWebhookHandlerServeHTTPValidationError(t *testing.T) {
+	// Arrange
+	violations := []domain.Violation{
+		{Pointer: "/query", Rule: "minLength", Message: "length must be >= 1, but got 0"},
+		{Pointer: "/timestamp", Rule: "minimum", Message: "must be >= 1 but found 0"},
+	}
+	processor := &MockWebhookProcessor{
+		ProcessError: fmt.Errorf("invalid analytics record: %w", &domain.ValidationError{Violations: violations}),
+	}
+	handler := NewWebhookHandler(processor, &MockHandlerLogger{}, domain.SignatureHeader)
+
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Webhook-Signature", "test_signature")
+	w := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", w.Code)
+	}
+	var response validationResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Success || !reflect.DeepEqual(response.Violations, violations) {
+		t.Errorf("Expected every violation in the response, got %s", w.Body.String())
+	}
+}
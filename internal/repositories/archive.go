@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// ArchiveRepository implements domain.AnalyticsWriter by appending every
+// operation to a JSON Lines stream, one object per line, for audit and replay
+// It is append-only: updates and deletes are recorded, not applied
+type ArchiveRepository struct {
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+// NewArchiveRepository creates an archive writing to out, usually a file
+// opened with os.O_APPEND
+func NewArchiveRepository(out io.Writer) *ArchiveRepository {
+	return &ArchiveRepository{out: out, now: time.Now}
+}
+
+// archiveLine is one archived operation
+type archiveLine struct {
+	Operation  string                 `json:"operation"`
+	TenantID   string                 `json:"tenantId"`
+	ArchivedAt int64                  `json:"archivedAt"`
+	RequestID  string                 `json:"requestId"`
+	Record     map[string]interface{} `json:"record,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	DeletedAt  int64                  `json:"deletedAt,omitempty"`
+}
+
+// Write appends the record
+func (r *ArchiveRepository) Write(ctx context.Context, record domain.AnalyticsRecord) error {
+	return r.WriteBatch(ctx, []domain.AnalyticsRecord{record})
+}
+
+// WriteBatch appends every record in one write, so a batch is never split
+// by lines from a concurrent request
+func (r *ArchiveRepository) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	lines := make([]archiveLine, len(records))
+	for i, record := range records {
+		lines[i] = r.line(ctx, "write", record.RequestID)
+		lines[i].Record = archiveRecord(record)
+	}
+	return r.append(lines...)
+}
+
+// Update appends the fields the patch sets
+func (r *ArchiveRepository) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	line := r.line(ctx, "update", patch.RequestID)
+	line.Fields = domain.PatchFields(patch)
+	return r.append(line)
+}
+
+// Tombstone appends the deletion time
+func (r *ArchiveRepository) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	line := r.line(ctx, "tombstone", requestID)
+	line.DeletedAt = deletedAt.UnixMilli()
+	return r.append(line)
+}
+
+// Delete appends the deletion; earlier lines for the record are kept
+func (r *ArchiveRepository) Delete(ctx context.Context, requestID string) error {
+	return r.append(r.line(ctx, "delete", requestID))
+}
+
+// line starts an archive line for the request's tenant
+func (r *ArchiveRepository) line(ctx context.Context, operation, requestID string) archiveLine {
+	return archiveLine{
+		Operation:  operation,
+		TenantID:   domain.TenantIDFromContext(ctx),
+		ArchivedAt: r.now().UnixMilli(),
+		RequestID:  requestID,
+	}
+}
+
+// append encodes lines and writes them with a single call
+func (r *ArchiveRepository) append(lines ...archiveLine) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, line := range lines {
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to encode archive line: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write analytics archive: %w", err)
+	}
+
+	return nil
+}
+
+// archiveRecord maps a record to its archived fields, named as in Firestore
+func archiveRecord(record domain.AnalyticsRecord) map[string]interface{} {
+	data := map[string]interface{}{
+		"requestId":     record.RequestID,
+		"query":         record.Query,
+		"matchType":     record.MatchType,
+		"matchScore":    record.MatchScore,
+		"reasoning":     record.Reasoning,
+		"vectorMatches": record.VectorMatches,
+		"sessionId":     record.SessionID,
+		"week":          record.Week,
+		"timestamp":     record.Timestamp,
+		"schemaVersion": record.SchemaVersion,
+	}
+
+	if len(record.Redactions) > 0 {
+		data["redactions"] = record.Redactions
+	}
+	if record.QueryNormalized != "" {
+		data["queryNormalized"] = record.QueryNormalized
+	}
+	if record.ScoreBand != "" {
+		data["scoreBand"] = record.ScoreBand
+	}
+	if record.ReceivedAtMillis > 0 {
+		data["receivedAtMillis"] = record.ReceivedAtMillis
+	}
+	if ce := record.CloudEvent; ce != nil {
+		data["cloudEvent"] = map[string]interface{}{
+			"id":     ce.ID,
+			"source": ce.Source,
+			"type":   ce.Type,
+			"time":   ce.Time,
+		}
+	}
+	if len(record.Extra) > 0 {
+		data["extra"] = record.Extra
+	}
+
+	return data
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+func TestArchiveRepositoryAppendsLines(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	archive := NewArchiveRepository(&out)
+	archive.now = func() time.Time { return time.UnixMilli(1700000000000) }
+	ctx := domain.WithTenant(context.Background(), &domain.Tenant{ID: "blog"})
+
+	// Act
+	_ = archive.WriteBatch(ctx, []domain.AnalyticsRecord{{RequestID: "r1", Query: "q"}, {RequestID: "r2"}})
+	_ = archive.Update(ctx, domain.AnalyticsRecord{RequestID: "r1", MatchScore: 80})
+	_ = archive.Delete(ctx, "r2")
+
+	// Assert
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d: %s", len(lines), out.String())
+	}
+	var first, update archiveLine
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Expected a JSON line, got %v", err)
+	}
+	_ = json.Unmarshal([]byte(lines[2]), &update)
+	if first.Operation != "write" || first.TenantID != "blog" || first.ArchivedAt != 1700000000000 || first.Record["query"] != "q" {
+		t.Errorf("Expected the written record with its tenant, got %+v", first)
+	}
+	if update.Operation != "update" || update.RequestID != "r1" || update.Fields["matchScore"] != float64(80) || len(update.Fields) != 1 {
+		t.Errorf("Expected only the patched field, got %+v", update)
+	}
+	if !strings.Contains(lines[3], `"operation":"delete"`) {
+		t.Errorf("Expected the delete recorded, got %s", lines[3])
+	}
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -23,7 +25,104 @@ func NewFirebaseRepository(client *db.Client) *FirebaseRepository {
 
 // Write stores an analytics record in Firebase
 func (r *FirebaseRepository) Write(ctx context.Context, record domain.AnalyticsRecord) error {
-	ref := r.client.NewRef("analytics/live")
+	// Push creates a new child with auto-generated key
+	data, err := r.data(ctx, record)
+	if err != nil {
+		return err
+	}
+	if _, err := r.ref(ctx).Push(ctx, data); err != nil {
+		return fmt.Errorf("failed to write analytics: %w", err)
+	}
+
+	return nil
+}
+
+// WriteBatch stores records in one multi-path update, which the database
+// applies atomically. Keys are generated locally in push-ID format so batch
+// children sort alongside those created by Push
+func (r *FirebaseRepository) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	updates := make(map[string]interface{}, len(records))
+	for _, record := range records {
+		key, err := pushKey(time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to generate analytics key: %w", err)
+		}
+		if updates[key], err = r.data(ctx, record); err != nil {
+			return err
+		}
+	}
+
+	if err := r.ref(ctx).Update(ctx, updates); err != nil {
+		return fmt.Errorf("failed to write analytics batch: %w", err)
+	}
+
+	return nil
+}
+
+// Update merges the patch's set fields into every child with its requestId
+func (r *FirebaseRepository) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	fields := domain.PatchFields(patch)
+	fields["updatedAt"] = time.Now().UnixMilli()
+
+	return r.updateMatches(ctx, patch.RequestID, func(key string, updates map[string]interface{}) {
+		for field, value := range fields {
+			updates[key+"/"+field] = value
+		}
+	})
+}
+
+// Tombstone marks matching children deleted but keeps them for audit
+func (r *FirebaseRepository) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	return r.updateMatches(ctx, requestID, func(key string, updates map[string]interface{}) {
+		updates[key+"/deleted"] = true
+		updates[key+"/deletedAt"] = deletedAt.UnixMilli()
+	})
+}
+
+// Delete removes matching children; a nil value in a multi-path update
+// deletes that path
+func (r *FirebaseRepository) Delete(ctx context.Context, requestID string) error {
+	return r.updateMatches(ctx, requestID, func(key string, updates map[string]interface{}) {
+		updates[key] = nil
+	})
+}
+
+// updateMatches finds the children written for requestID (Push keys are not
+// the requestId, so this needs ".indexOn": "requestId" in the database rules)
+// and applies the paths set by apply for each in one atomic update
+func (r *FirebaseRepository) updateMatches(ctx context.Context, requestID string, apply func(key string, updates map[string]interface{})) error {
+	var matches map[string]interface{}
+	if err := r.ref(ctx).OrderByChild("requestId").EqualTo(requestID).Get(ctx, &matches); err != nil {
+		return fmt.Errorf("failed to look up analytics: %w", err)
+	}
+	if len(matches) == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	updates := make(map[string]interface{})
+	for key := range matches {
+		apply(key, updates)
+	}
+
+	if err := r.ref(ctx).Update(ctx, updates); err != nil {
+		return fmt.Errorf("failed to modify analytics: %w", err)
+	}
+
+	return nil
+}
+
+// ref returns the live analytics path; each tenant writes under its own prefix
+func (r *FirebaseRepository) ref(ctx context.Context) *db.Ref {
+	tenant := domain.TenantFromContext(ctx)
+	return r.client.NewRef(tenant.AnalyticsCollection("analytics") + "/live")
+}
+
+// data maps a record to its stored fields
+func (r *FirebaseRepository) data(ctx context.Context, record domain.AnalyticsRecord) (map[string]interface{}, error) {
+	receivedAt := time.Now()
+	if record.ReceivedAtMillis > 0 {
+		receivedAt = time.UnixMilli(record.ReceivedAtMillis)
+	}
 
 	data := map[string]interface{}{
 		"requestId":     record.RequestID,
@@ -35,13 +134,72 @@ func (r *FirebaseRepository) Write(ctx context.Context, record domain.AnalyticsR
 		"sessionId":     record.SessionID,
 		"week":          record.Week,
 		"timestamp":     record.Timestamp,
-		"receivedAt":    time.Now().UnixMilli(),
+		"schemaVersion": record.SchemaVersion,
+		"tenantId":      domain.TenantIDFromContext(ctx),
+		"receivedAt":    receivedAt.UnixMilli(),
 	}
 
-	// Push creates a new child with auto-generated key
-	if _, err := ref.Push(ctx, data); err != nil {
-		return fmt.Errorf("failed to write analytics: %w", err)
+	// Redaction rules that fired, so masked records can be found and counted
+	if len(record.Redactions) > 0 {
+		data["redactions"] = record.Redactions
 	}
 
-	return nil
+	// Fields derived by the enrichers
+	if record.QueryNormalized != "" {
+		data["queryNormalized"] = record.QueryNormalized
+	}
+	if record.ScoreBand != "" {
+		data["scoreBand"] = record.ScoreBand
+	}
+	if record.ReceivedAtMillis > 0 {
+		data["receivedAtSeconds"] = record.ReceivedAt
+		data["receivedAtMillis"] = record.ReceivedAtMillis
+	}
+
+	// Keep the CloudEvents context so events can be traced back to their producer
+	if ce := record.CloudEvent; ce != nil {
+		data["cloudEvent"] = map[string]interface{}{
+			"id":     ce.ID,
+			"source": ce.Source,
+			"type":   ce.Type,
+			"time":   ce.Time,
+		}
+	}
+
+	// Fields the receiver does not know yet, kept by permissive decoding
+	// Stored as a JSON string: RTDB rejects keys containing . $ # [ ] or /,
+	// and unknown field names are chosen by the sender
+	if len(record.Extra) > 0 {
+		extra, err := json.Marshal(record.Extra)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode extra fields: %w", err)
+		}
+		data["extra"] = string(extra)
+	}
+
+	return data, nil
+}
+
+// pushKeyChars is the ordered alphabet Firebase uses for push IDs
+const pushKeyChars = "-0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz"
+
+// pushKey builds a 20-character push ID: 8 characters of millisecond
+// timestamp followed by 12 random characters
+func pushKey(now time.Time) (string, error) {
+	key := make([]byte, 20)
+
+	ms := now.UnixMilli()
+	for i := 7; i >= 0; i-- {
+		key[i] = pushKeyChars[ms%64]
+		ms /= 64
+	}
+
+	if _, err := rand.Read(key[8:]); err != nil {
+		return "", err
+	}
+	for i := 8; i < len(key); i++ {
+		key[i] = pushKeyChars[key[i]%64]
+	}
+
+	return string(key), nil
 }
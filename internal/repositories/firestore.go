@@ -7,8 +7,16 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"example.com/webhook-receiver/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Transient Firestore errors are retried in place: up to firestoreAttempts
+// tries, waiting firestoreBackoff and then twice that
+const firestoreAttempts = 3
+
+var firestoreBackoff = 100 * time.Millisecond
+
 // FirestoreRepository implements domain.AnalyticsWriter using Firestore
 // Uses requestId as document ID for guaranteed idempotency
 type FirestoreRepository struct {
@@ -25,8 +33,128 @@ func NewFirestoreRepository(client *firestore.Client) *FirestoreRepository {
 // Write stores an analytics record in Firestore
 // Uses requestId as document ID to prevent duplicates (idempotent)
 func (r *FirestoreRepository) Write(ctx context.Context, record domain.AnalyticsRecord) error {
-	// Use requestId as document ID for idempotency
-	docRef := r.client.Collection("analytics").Doc(record.RequestID)
+	// Set overwrites if document exists (idempotent operation)
+	doc, data := r.doc(ctx, record), r.data(ctx, record)
+	if err := retryTransient(ctx, func() error {
+		_, err := doc.Set(ctx, data)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write analytics to Firestore: %w", err)
+	}
+
+	return nil
+}
+
+// WriteBatch stores records in one atomic batch (at most domain.MaxBatchRecords)
+func (r *FirestoreRepository) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	batch := r.client.Batch()
+	for _, record := range records {
+		batch.Set(r.doc(ctx, record), r.data(ctx, record))
+	}
+
+	if err := retryTransient(ctx, func() error {
+		_, err := batch.Commit(ctx)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write analytics batch to Firestore: %w", err)
+	}
+
+	return nil
+}
+
+// Update merges the patch's set fields into an existing record
+func (r *FirestoreRepository) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	fields := domain.PatchFields(patch)
+	updates := make([]firestore.Update, 0, len(fields)+1)
+	for path, value := range fields {
+		updates = append(updates, firestore.Update{Path: path, Value: value})
+	}
+	updates = append(updates, firestore.Update{Path: "updatedAt", Value: time.Now().Unix()})
+
+	return r.update(ctx, patch.RequestID, updates, "update")
+}
+
+// Tombstone marks a record deleted but keeps it for audit
+func (r *FirestoreRepository) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	return r.update(ctx, requestID, []firestore.Update{
+		{Path: "deleted", Value: true},
+		{Path: "deletedAt", Value: deletedAt.Unix()},
+	}, "tombstone")
+}
+
+// Delete removes a record; the Exists precondition surfaces missing records
+// It is not retried: a delete that landed but timed out would then be reported
+// as not found
+func (r *FirestoreRepository) Delete(ctx context.Context, requestID string) error {
+	doc := r.doc(ctx, domain.AnalyticsRecord{RequestID: requestID})
+	if _, err := doc.Delete(ctx, firestore.Exists); err != nil {
+		return notFound(err, "failed to delete analytics in Firestore: %w")
+	}
+
+	return nil
+}
+
+// update applies updates to an existing record (Update fails if it is missing)
+func (r *FirestoreRepository) update(ctx context.Context, requestID string, updates []firestore.Update, op string) error {
+	doc := r.doc(ctx, domain.AnalyticsRecord{RequestID: requestID})
+	if err := retryTransient(ctx, func() error {
+		_, err := doc.Update(ctx, updates)
+		return err
+	}); err != nil {
+		return notFound(err, "failed to "+op+" analytics in Firestore: %w")
+	}
+
+	return nil
+}
+
+// notFound maps a gRPC NotFound to domain.ErrRecordNotFound and wraps
+// everything else with format
+func notFound(err error, format string) error {
+	if status.Code(err) == codes.NotFound {
+		return domain.ErrRecordNotFound
+	}
+	return fmt.Errorf(format, err)
+}
+
+// retryTransient runs op until it succeeds, fails with an error that is not
+// transient, or has been tried firestoreAttempts times
+func retryTransient(ctx context.Context, op func() error) error {
+	var err error
+	for attempt := 1; attempt <= firestoreAttempts; attempt++ {
+		if err = op(); err == nil || !transient(err) || attempt == firestoreAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * firestoreBackoff):
+		}
+	}
+	return err
+}
+
+// transient reports whether a gRPC error is worth retrying
+func transient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// doc returns the record's document, keyed by requestId for idempotency
+// Each tenant writes to its own prefixed collection
+func (r *FirestoreRepository) doc(ctx context.Context, record domain.AnalyticsRecord) *firestore.DocumentRef {
+	tenant := domain.TenantFromContext(ctx)
+	return r.client.Collection(tenant.AnalyticsCollection("analytics")).Doc(record.RequestID)
+}
+
+// data maps a record to its stored fields
+func (r *FirestoreRepository) data(ctx context.Context, record domain.AnalyticsRecord) map[string]interface{} {
+	receivedAt := time.Now()
+	if record.ReceivedAtMillis > 0 {
+		receivedAt = time.UnixMilli(record.ReceivedAtMillis)
+	}
 
 	data := map[string]interface{}{
 		"requestId":     record.RequestID,
@@ -38,13 +166,42 @@ func (r *FirestoreRepository) Write(ctx context.Context, record domain.Analytics
 		"sessionId":     record.SessionID,
 		"week":          record.Week,
 		"timestamp":     record.Timestamp,
-		"receivedAt":    time.Now().Unix(),
+		"schemaVersion": record.SchemaVersion,
+		"tenantId":      domain.TenantIDFromContext(ctx),
+		"receivedAt":    receivedAt.Unix(),
 	}
 
-	// Set overwrites if document exists (idempotent operation)
-	if _, err := docRef.Set(ctx, data); err != nil {
-		return fmt.Errorf("failed to write analytics to Firestore: %w", err)
+	// Redaction rules that fired, so masked records can be found and counted
+	if len(record.Redactions) > 0 {
+		data["redactions"] = record.Redactions
 	}
 
-	return nil
+	// Fields derived by the enrichers
+	if record.QueryNormalized != "" {
+		data["queryNormalized"] = record.QueryNormalized
+	}
+	if record.ScoreBand != "" {
+		data["scoreBand"] = record.ScoreBand
+	}
+	if record.ReceivedAtMillis > 0 {
+		data["receivedAtSeconds"] = record.ReceivedAt
+		data["receivedAtMillis"] = record.ReceivedAtMillis
+	}
+
+	// Keep the CloudEvents context so events can be traced back to their producer
+	if ce := record.CloudEvent; ce != nil {
+		data["cloudEvent"] = map[string]interface{}{
+			"id":     ce.ID,
+			"source": ce.Source,
+			"type":   ce.Type,
+			"time":   ce.Time,
+		}
+	}
+
+	// Fields the receiver does not know yet, kept by permissive decoding
+	if len(record.Extra) > 0 {
+		data["extra"] = record.Extra
+	}
+
+	return data
 }
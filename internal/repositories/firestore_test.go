@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryTransient(t *testing.T) {
+	firestoreBackoff = time.Millisecond
+	defer func() { firestoreBackoff = 100 * time.Millisecond }()
+
+	cases := map[string]struct {
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		"succeeds first time":     {[]error{nil}, 1, false},
+		"recovers from transient": {[]error{status.Error(codes.Unavailable, "down"), status.Error(codes.Aborted, "contention"), nil}, 3, false},
+		"gives up after attempts": {[]error{status.Error(codes.DeadlineExceeded, "slow"), status.Error(codes.DeadlineExceeded, "slow"), status.Error(codes.DeadlineExceeded, "slow"), nil}, 3, true},
+		"permanent not retried":   {[]error{status.Error(codes.PermissionDenied, "denied"), nil}, 1, true},
+		"plain error not retried": {[]error{errors.New("encode"), nil}, 1, true},
+	}
+
+	for name, tc := range cases {
+		calls := 0
+		err := retryTransient(context.Background(), func() error {
+			calls++
+			return tc.errs[calls-1]
+		})
+
+		if calls != tc.wantCalls || (err != nil) != tc.wantErr {
+			t.Errorf("%s: expected %d calls (error %v), got %d (%v)", name, tc.wantCalls, tc.wantErr, calls, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirestoreNonceStore implements domain.NonceStore using Firestore
+// Shared by all function instances; configure a Firestore TTL policy on
+// "expiresAt" to have expired documents deleted automatically
+type FirestoreNonceStore struct {
+	client     *firestore.Client
+	collection string
+	ttl        time.Duration
+}
+
+// NewFirestoreNonceStore creates a Firestore-backed nonce store
+func NewFirestoreNonceStore(client *firestore.Client, ttl time.Duration) *FirestoreNonceStore {
+	return &FirestoreNonceStore{
+		client:     client,
+		collection: "webhook_nonces",
+		ttl:        ttl,
+	}
+}
+
+// Reserve records id in a transaction and reports false if it is already present and unexpired
+func (s *FirestoreNonceStore) Reserve(ctx context.Context, id string) (bool, error) {
+	docRef := s.docRef(id)
+	fresh := false
+
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		fresh = false
+		now := time.Now()
+
+		snap, err := tx.Get(docRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if expiresAt, ok := snap.Data()["expiresAt"].(time.Time); ok && now.Before(expiresAt) {
+				return nil
+			}
+		}
+
+		fresh = true
+		return tx.Set(docRef, map[string]interface{}{
+			"messageId": id,
+			"expiresAt": now.Add(s.ttl),
+		})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve message id in Firestore: %w", err)
+	}
+
+	return fresh, nil
+}
+
+// Release deletes the nonce document for id
+func (s *FirestoreNonceStore) Release(ctx context.Context, id string) error {
+	if _, err := s.docRef(id).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to release message id in Firestore: %w", err)
+	}
+	return nil
+}
+
+// docRef hashes id because sender IDs may contain characters Firestore
+// does not allow in document IDs (e.g. "/")
+func (s *FirestoreNonceStore) docRef(id string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(id))
+	return s.client.Collection(s.collection).Doc(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryNonceStore implements domain.NonceStore in process memory
+// Safe for concurrent use; a background goroutine evicts expired entries
+// Entries are per instance, so use FirestoreNonceStore when scaled out
+type MemoryNonceStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]time.Time // message ID -> expiry
+	now     func() time.Time
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryNonceStore creates an in-memory nonce store
+// ttl should be at least the signature tolerance window
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	s := &MemoryNonceStore{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+	go s.evictLoop(ttl / 2)
+	return s
+}
+
+// Reserve records id and reports false if it is already present and unexpired
+func (s *MemoryNonceStore) Reserve(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expiresAt, ok := s.entries[id]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+
+	s.entries[id] = now.Add(s.ttl)
+	return true, nil
+}
+
+// Release forgets id
+func (s *MemoryNonceStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, id)
+	return nil
+}
+
+// Close stops the eviction goroutine
+func (s *MemoryNonceStore) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// evictLoop periodically removes expired entries so memory stays bounded
+func (s *MemoryNonceStore) evictLoop(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.evictExpired()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// evictExpired removes every entry whose TTL has passed
+func (s *MemoryNonceStore) evictExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, expiresAt := range s.entries {
+		if !now.Before(expiresAt) {
+			delete(s.entries, id)
+		}
+	}
+}
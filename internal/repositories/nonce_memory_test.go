@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryNonceStoreReserve(t *testing.T) {
+	// Arrange
+	store := NewMemoryNonceStore(time.Minute)
+	defer store.Close()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Act & Assert
+	if fresh, _ := store.Reserve(ctx, "msg_1"); !fresh {
+		t.Errorf("Expected first reservation to be fresh")
+	}
+	if fresh, _ := store.Reserve(ctx, "msg_1"); fresh {
+		t.Errorf("Expected second reservation to be a duplicate")
+	}
+
+	store.Release(ctx, "msg_1")
+	if fresh, _ := store.Reserve(ctx, "msg_1"); !fresh {
+		t.Errorf("Expected released ID to be reservable again")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if fresh, _ := store.Reserve(ctx, "msg_1"); !fresh {
+		t.Errorf("Expected expired ID to be reservable again")
+	}
+}
+
+func TestMemoryNonceStoreEvictsExpired(t *testing.T) {
+	store := NewMemoryNonceStore(time.Minute)
+	defer store.Close()
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+	store.Reserve(context.Background(), "msg_1")
+
+	now = now.Add(2 * time.Minute)
+	store.evictExpired()
+
+	if len(store.entries) != 0 {
+		t.Errorf("Expected expired entry to be evicted, got %d entries", len(store.entries))
+	}
+}
+
+func TestMemoryNonceStoreConcurrentReserve(t *testing.T) {
+	// Exactly one of many concurrent deliveries of the same ID wins
+	store := NewMemoryNonceStore(time.Minute)
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	fresh := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := store.Reserve(context.Background(), "msg_1"); ok {
+				mu.Lock()
+				fresh++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fresh != 1 {
+		t.Errorf("Expected exactly 1 fresh reservation, got %d", fresh)
+	}
+}
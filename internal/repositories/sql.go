@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// numberedPlaceholders are the drivers that bind $1, $2, ... instead of ?
+var numberedPlaceholders = map[string]bool{"postgres": true, "pgx": true}
+
+// sqlColumns maps the fields an update may set (see domain.PatchFields) to columns
+var sqlColumns = map[string]string{
+	"query":           "query",
+	"matchType":       "match_type",
+	"matchScore":      "match_score",
+	"reasoning":       "reasoning",
+	"vectorMatches":   "vector_matches",
+	"sessionId":       "session_id",
+	"week":            "week",
+	"timestamp":       "timestamp_ms",
+	"redactions":      "redactions",
+	"queryNormalized": "query_normalized",
+	"scoreBand":       "score_band",
+}
+
+// SQLRepository implements domain.AnalyticsWriter on a reporting table through
+// database/sql, one row per tenant and requestId. The table is:
+//
+//	CREATE TABLE analytics_records (
+//	  tenant_id TEXT NOT NULL, request_id TEXT NOT NULL,
+//	  query TEXT, match_type TEXT, match_score INTEGER, reasoning TEXT,
+//	  vector_matches INTEGER, session_id TEXT, week TEXT, timestamp_ms BIGINT,
+//	  schema_version INTEGER, query_normalized TEXT, score_band TEXT,
+//	  received_at_ms BIGINT, redactions TEXT, extra TEXT,
+//	  updated_at_ms BIGINT, deleted_at_ms BIGINT,
+//	  PRIMARY KEY (tenant_id, request_id))
+type SQLRepository struct {
+	db       *sql.DB
+	table    string
+	numbered bool
+}
+
+// NewSQLRepository creates a repository on table, which must be a plain
+// identifier; driver picks the placeholder style
+func NewSQLRepository(db *sql.DB, driver, table string) *SQLRepository {
+	return &SQLRepository{db: db, table: table, numbered: numberedPlaceholders[driver]}
+}
+
+// Write stores a record, replacing any row with its requestId
+func (r *SQLRepository) Write(ctx context.Context, record domain.AnalyticsRecord) error {
+	return r.WriteBatch(ctx, []domain.AnalyticsRecord{record})
+}
+
+// WriteBatch stores records in one transaction
+// Replacing is a delete then an insert, which every database supports
+func (r *SQLRepository) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin analytics transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tenantID := domain.TenantIDFromContext(ctx)
+	remove := r.bind("DELETE FROM " + r.table + " WHERE tenant_id = ? AND request_id = ?")
+	insert := r.bind("INSERT INTO " + r.table + ` (tenant_id, request_id, query, match_type, match_score,
+		reasoning, vector_matches, session_id, week, timestamp_ms, schema_version, query_normalized,
+		score_band, received_at_ms, redactions, extra) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	for _, record := range records {
+		extra, err := sqlExtra(record.Extra)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, remove, tenantID, record.RequestID); err != nil {
+			return fmt.Errorf("failed to write analytics to SQL: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, tenantID, record.RequestID, record.Query, record.MatchType,
+			record.MatchScore, record.Reasoning, record.VectorMatches, record.SessionID, record.Week,
+			record.Timestamp, record.SchemaVersion, record.QueryNormalized, record.ScoreBand,
+			record.ReceivedAtMillis, strings.Join(record.Redactions, ","), extra); err != nil {
+			return fmt.Errorf("failed to write analytics to SQL: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit analytics to SQL: %w", err)
+	}
+
+	return nil
+}
+
+// Update sets the patch's fields on an existing row
+func (r *SQLRepository) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	fields := domain.PatchFields(patch)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, 0, len(names)+1)
+	args := make([]interface{}, 0, len(names)+3)
+	for _, name := range names {
+		value := fields[name]
+		if redactions, ok := value.([]string); ok {
+			value = strings.Join(redactions, ",")
+		}
+		assignments = append(assignments, sqlColumns[name]+" = ?")
+		args = append(args, value)
+	}
+	assignments = append(assignments, "updated_at_ms = ?")
+	args = append(args, time.Now().UnixMilli())
+
+	return r.modify(ctx, "update", "UPDATE "+r.table+" SET "+strings.Join(assignments, ", "), args, patch.RequestID)
+}
+
+// Tombstone marks a row deleted but keeps it for audit
+func (r *SQLRepository) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	return r.modify(ctx, "tombstone", "UPDATE "+r.table+" SET deleted_at_ms = ?", []interface{}{deletedAt.UnixMilli()}, requestID)
+}
+
+// Delete removes a row
+func (r *SQLRepository) Delete(ctx context.Context, requestID string) error {
+	return r.modify(ctx, "delete", "DELETE FROM "+r.table, nil, requestID)
+}
+
+// modify runs statement on the tenant's row for requestID and reports
+// domain.ErrRecordNotFound when there is none
+func (r *SQLRepository) modify(ctx context.Context, op, statement string, args []interface{}, requestID string) error {
+	args = append(args, domain.TenantIDFromContext(ctx), requestID)
+	result, err := r.db.ExecContext(ctx, r.bind(statement+" WHERE tenant_id = ? AND request_id = ?"), args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s analytics in SQL: %w", op, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to %s analytics in SQL: %w", op, err)
+	}
+	if rows == 0 {
+		return domain.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// bind rewrites ? placeholders for drivers that number them
+func (r *SQLRepository) bind(statement string) string {
+	if !r.numbered {
+		return statement
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range statement {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// sqlExtra encodes the unknown fields as JSON text, or NULL when there are none
+func sqlExtra(extra map[string]interface{}) (interface{}, error) {
+	if len(extra) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(extra)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode extra fields: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// recordingDriver is a database/sql driver that records statements
+// RowsAffected is what every statement reports
+type recordingDriver struct {
+	mu           sync.Mutex
+	Statements   []string
+	Args         [][]driver.NamedValue
+	RowsAffected int64
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Commit() error             { return nil }
+func (c *recordingConn) Rollback() error           { return nil }
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.Statements = append(c.d.Statements, query)
+	c.d.Args = append(c.d.Args, args)
+	return driver.RowsAffected(c.d.RowsAffected), nil
+}
+
+func newTestSQLRepository(t *testing.T, driverName string, rows int64) (*SQLRepository, *recordingDriver) {
+	t.Helper()
+	recorder := &recordingDriver{RowsAffected: rows}
+	db := sql.OpenDB(connector{recorder})
+	t.Cleanup(func() { db.Close() })
+	return NewSQLRepository(db, driverName, "analytics_records"), recorder
+}
+
+// connector opens recordingDriver connections without registering it
+type connector struct{ d *recordingDriver }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c connector) Driver() driver.Driver                        { return c.d }
+
+func TestSQLRepositoryWriteReplacesTenantRow(t *testing.T) {
+	// Arrange
+	repo, recorder := newTestSQLRepository(t, "pgx", 1)
+	ctx := domain.WithTenant(context.Background(), &domain.Tenant{ID: "blog"})
+
+	// Act
+	err := repo.Write(ctx, domain.AnalyticsRecord{RequestID: "r1", Query: "q", Extra: map[string]interface{}{"locale": "de"}})
+
+	// Assert
+	if err != nil || len(recorder.Statements) != 2 {
+		t.Fatalf("Expected a delete and an insert, got %v (%v)", recorder.Statements, err)
+	}
+	if !strings.HasPrefix(recorder.Statements[0], "DELETE FROM analytics_records WHERE tenant_id = $1 AND request_id = $2") {
+		t.Errorf("Expected numbered placeholders for pgx, got %q", recorder.Statements[0])
+	}
+	insert := recorder.Args[1]
+	if insert[0].Value != "blog" || insert[1].Value != "r1" || insert[15].Value != `{"locale":"de"}` {
+		t.Errorf("Expected tenant, requestId and extra bound, got %v", insert)
+	}
+}
+
+func TestSQLRepositoryUpdate(t *testing.T) {
+	// Arrange
+	repo, recorder := newTestSQLRepository(t, "mysql", 1)
+	missing, _ := newTestSQLRepository(t, "mysql", 0)
+
+	// Act
+	err := repo.Update(context.Background(), domain.AnalyticsRecord{RequestID: "r1", MatchScore: 80, Week: "2023-W46"})
+	missingErr := missing.Tombstone(context.Background(), "r1", time.Unix(1700000000, 0))
+
+	// Assert
+	want := "UPDATE analytics_records SET match_score = ?, week = ?, updated_at_ms = ? WHERE tenant_id = ? AND request_id = ?"
+	if err != nil || len(recorder.Statements) != 1 || recorder.Statements[0] != want {
+		t.Errorf("Expected %q, got %v (%v)", want, recorder.Statements, err)
+	}
+	if !errors.Is(missingErr, domain.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound when no row matches, got %v", missingErr)
+	}
+}
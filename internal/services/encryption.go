@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// EncryptingWriter encrypts domain.EncryptedFields before passing records to
+// the wrapped writer, so the store only ever sees ciphertext
+// It forwards batches, updates and deletes when the wrapped writer supports them
+type EncryptingWriter struct {
+	next   domain.AnalyticsWriter
+	cipher *domain.FieldCipher
+}
+
+// NewEncryptingWriter wraps next with field encryption
+func NewEncryptingWriter(next domain.AnalyticsWriter, cipher *domain.FieldCipher) *EncryptingWriter {
+	return &EncryptingWriter{next: next, cipher: cipher}
+}
+
+// Write implements domain.AnalyticsWriter
+func (w *EncryptingWriter) Write(ctx context.Context, record domain.AnalyticsRecord) error {
+	encrypted, err := w.cipher.EncryptRecord(record)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt record: %w", err)
+	}
+	return w.next.Write(ctx, encrypted)
+}
+
+// WriteBatch implements domain.BatchWriter
+func (w *EncryptingWriter) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	encrypted := make([]domain.AnalyticsRecord, len(records))
+	for i, record := range records {
+		var err error
+		if encrypted[i], err = w.cipher.EncryptRecord(record); err != nil {
+			return fmt.Errorf("failed to encrypt record: %w", err)
+		}
+	}
+	return writeAll(ctx, w.next, encrypted)
+}
+
+// Update implements domain.RecordUpdater
+func (w *EncryptingWriter) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	updater, ok := w.next.(domain.RecordUpdater)
+	if !ok {
+		return fmt.Errorf("analytics writer %T does not support updates", w.next)
+	}
+	encrypted, err := w.cipher.EncryptRecord(patch)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt record: %w", err)
+	}
+	return updater.Update(ctx, encrypted)
+}
+
+// Delete implements domain.RecordDeleter
+func (w *EncryptingWriter) Delete(ctx context.Context, requestID string) error {
+	deleter, ok := w.next.(domain.RecordDeleter)
+	if !ok {
+		return fmt.Errorf("analytics writer %T does not support deletes", w.next)
+	}
+	return deleter.Delete(ctx, requestID)
+}
+
+// Tombstone implements domain.RecordDeleter
+func (w *EncryptingWriter) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	deleter, ok := w.next.(domain.RecordDeleter)
+	if !ok {
+		return fmt.Errorf("analytics writer %T does not support deletes", w.next)
+	}
+	return deleter.Tombstone(ctx, requestID, deletedAt)
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+func newTestFieldCipher(t *testing.T) *domain.FieldCipher {
+	t.Helper()
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	keys, err := domain.ParseKeyFile([]byte(`{"current": "k1", "keys": {"k1": "` + key + `"}}`))
+	if err != nil {
+		t.Fatalf("Expected keyfile to parse, got %v", err)
+	}
+	return domain.NewFieldCipher(keys)
+}
+
+func TestEncryptingWriterStoresCiphertextOnly(t *testing.T) {
+	// Arrange
+	cipher := newTestFieldCipher(t)
+	store := &MockRecordStore{}
+	writer := NewEncryptingWriter(store, cipher)
+	record := domain.AnalyticsRecord{RequestID: "r1", Query: "Go jobs?", Reasoning: "matched Go", MatchScore: 80}
+
+	// Act
+	writeErr := writer.Write(context.Background(), record)
+	updateErr := writer.Update(context.Background(), domain.AnalyticsRecord{RequestID: "r1", Reasoning: "rescored"})
+
+	// Assert
+	if writeErr != nil || updateErr != nil {
+		t.Fatalf("Expected no errors, got %v and %v", writeErr, updateErr)
+	}
+	stored, patch := store.WrittenRecords[0], store.Patches[0]
+	if strings.Contains(stored.Query, "Go jobs") || strings.Contains(stored.Reasoning, "matched") || strings.Contains(patch.Reasoning, "rescored") {
+		t.Errorf("Expected only ciphertext to reach the store, got %+v and %+v", stored, patch)
+	}
+	if patch.Query != "" || stored.MatchScore != 80 {
+		t.Errorf("Expected unset and non-sensitive fields unchanged, got %+v and %+v", stored, patch)
+	}
+	if decrypted, err := cipher.DecryptRecord(stored); err != nil || decrypted.Query != record.Query {
+		t.Errorf("Expected the stored record to decrypt, got %+v (%v)", decrypted, err)
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// DeleteMode selects how analytics_record_deleted events are applied
+type DeleteMode string
+
+// Delete modes
+const (
+	DeleteTombstone DeleteMode = "tombstone"
+	DeleteHard      DeleteMode = "delete"
+)
+
+// NewDefaultEventRouter registers the built-in analytics event handlers
+// Other event types can be added with Register on the returned router
+func NewDefaultEventRouter(
+	writer domain.AnalyticsWriter,
+	recordValidator domain.RecordValidator,
+	enricher domain.Enricher,
+	nonces domain.NonceStore,
+	logger domain.Logger,
+	deleteMode DeleteMode,
+) *domain.EventRouter {
+	router := domain.NewEventRouter()
+	router.Register(domain.EventTypeCreated, &CreatedHandler{writer: writer, recordValidator: recordValidator, enricher: enricher, nonces: nonces, logger: logger})
+	router.Register(domain.EventTypeUpdated, &UpdatedHandler{writer: writer, recordValidator: recordValidator, enricher: enricher, logger: logger})
+	router.Register(domain.EventTypeDeleted, &DeletedHandler{writer: writer, logger: logger, mode: deleteMode, now: time.Now})
+	router.Register(domain.EventTypeBatch, &BatchHandler{writer: writer, recordValidator: recordValidator, enricher: enricher, nonces: nonces, logger: logger})
+	return router
+}
+
+// CreatedHandler validates, enriches and stores a new record
+// It reserves the record's requestId as batches do, so a record sent singly
+// and again in a batch (or the other way round) is only written once
+type CreatedHandler struct {
+	writer          domain.AnalyticsWriter
+	recordValidator domain.RecordValidator
+	enricher        domain.Enricher
+	nonces          domain.NonceStore
+	logger          domain.Logger
+}
+
+// Handle implements domain.EventHandler
+func (h *CreatedHandler) Handle(ctx context.Context, payload *domain.WebhookPayload) (*domain.ProcessResult, error) {
+	if err := h.recordValidator.ValidateRecord(&payload.Data); err != nil {
+		h.logger.Error("analytics record validation failed", err)
+		return nil, fmt.Errorf("invalid analytics record: %w", err)
+	}
+	enriched, err := domain.EnrichRecord(payload.Data, h.enricher, h.recordValidator.ValidateRecord)
+	if err != nil {
+		h.logger.Error("enriched analytics record validation failed", err)
+		return nil, fmt.Errorf("invalid analytics record: %w", err)
+	}
+	payload.Data = enriched
+
+	id := recordID(ctx, payload.Data.RequestID)
+	fresh, err := h.nonces.Reserve(ctx, id)
+	if err != nil {
+		h.logger.Error("failed to check record id", err)
+		return nil, storageError("failed to check record id", err)
+	}
+	if !fresh {
+		h.logger.Info("duplicate record ignored", "requestId", payload.Data.RequestID)
+		return nil, domain.ErrDuplicateDelivery
+	}
+
+	if err := h.writer.Write(ctx, payload.Data); err != nil {
+		h.logger.Error("failed to write analytics", err)
+		releaseIDs(ctx, h.nonces, h.logger, id)
+		return nil, storageError("failed to store analytics", err)
+	}
+	return &domain.ProcessResult{}, nil
+}
+
+// UpdatedHandler merges the fields set in the payload into a stored record
+// The fields are checked by the record validator if it supports patches, and
+// enriched so derived fields such as queryNormalized follow them
+type UpdatedHandler struct {
+	writer          domain.AnalyticsWriter
+	recordValidator domain.RecordValidator
+	enricher        domain.Enricher
+	logger          domain.Logger
+}
+
+// Handle implements domain.EventHandler
+func (h *UpdatedHandler) Handle(ctx context.Context, payload *domain.WebhookPayload) (*domain.ProcessResult, error) {
+	updater, ok := h.writer.(domain.RecordUpdater)
+	if !ok {
+		return nil, fmt.Errorf("analytics writer %T does not support updates", h.writer)
+	}
+	if payload.Data.RequestID == "" {
+		return nil, fmt.Errorf("invalid analytics record: requestId is required")
+	}
+	validatePatch := func(*domain.AnalyticsRecord) error { return nil }
+	if patches, ok := h.recordValidator.(domain.PatchValidator); ok {
+		validatePatch = patches.ValidatePatch
+	}
+	if err := validatePatch(&payload.Data); err != nil {
+		h.logger.Error("analytics update validation failed", err)
+		return nil, fmt.Errorf("invalid analytics record: %w", err)
+	}
+	if len(domain.PatchFields(payload.Data)) == 0 {
+		return nil, fmt.Errorf("invalid analytics record: update sets no fields")
+	}
+	enriched, err := domain.EnrichPatch(payload.Data, h.enricher, validatePatch)
+	if err != nil {
+		h.logger.Error("enriched analytics update validation failed", err)
+		return nil, fmt.Errorf("invalid analytics record: %w", err)
+	}
+	payload.Data = enriched
+
+	if err := updater.Update(ctx, payload.Data); err != nil {
+		h.logger.Error("failed to update analytics", err)
+		return nil, storageError("failed to update analytics", err)
+	}
+	return &domain.ProcessResult{}, nil
+}
+
+// DeletedHandler removes a stored record or marks it with a tombstone
+type DeletedHandler struct {
+	writer domain.AnalyticsWriter
+	logger domain.Logger
+	mode   DeleteMode
+	now    func() time.Time
+}
+
+// Handle implements domain.EventHandler
+func (h *DeletedHandler) Handle(ctx context.Context, payload *domain.WebhookPayload) (*domain.ProcessResult, error) {
+	deleter, ok := h.writer.(domain.RecordDeleter)
+	if !ok {
+		return nil, fmt.Errorf("analytics writer %T does not support deletes", h.writer)
+	}
+	if payload.Data.RequestID == "" {
+		return nil, fmt.Errorf("invalid analytics record: requestId is required")
+	}
+
+	var err error
+	if h.mode == DeleteHard {
+		err = deleter.Delete(ctx, payload.Data.RequestID)
+	} else {
+		err = deleter.Tombstone(ctx, payload.Data.RequestID, h.now())
+	}
+	if err != nil {
+		h.logger.Error("failed to delete analytics", err)
+		return nil, storageError("failed to delete analytics", err)
+	}
+	return &domain.ProcessResult{}, nil
+}
+
+// BatchHandler validates each record of a batch on its own, skips records
+// already delivered, and writes the rest together. Invalid and duplicate
+// records are reported rather than failing the batch, so the sender retries
+// only those
+type BatchHandler struct {
+	writer          domain.AnalyticsWriter
+	recordValidator domain.RecordValidator
+	enricher        domain.Enricher
+	nonces          domain.NonceStore
+	logger          domain.Logger
+}
+
+// Handle implements domain.EventHandler
+func (h *BatchHandler) Handle(ctx context.Context, payload *domain.WebhookPayload) (*domain.ProcessResult, error) {
+	records := payload.Records
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: batch has no records", domain.ErrInvalidPayload)
+	}
+	if len(records) > domain.MaxBatchRecords {
+		return nil, fmt.Errorf("%w: %d records, limit %d", domain.ErrBatchTooLarge, len(records), domain.MaxBatchRecords)
+	}
+
+	result := &domain.ProcessResult{Records: make([]domain.RecordOutcome, len(records))}
+	var accepted []domain.AnalyticsRecord
+	var reserved []string
+
+	for i := range records {
+		outcome := &result.Records[i]
+		outcome.Index = i
+		outcome.RequestID = records[i].RequestID
+
+		if err := h.recordValidator.ValidateRecord(&records[i]); err != nil {
+			outcome.Status = domain.RecordInvalid
+			outcome.Reason, outcome.Violations = domain.Rejection(err)
+			continue
+		}
+		enriched, err := domain.EnrichRecord(records[i], h.enricher, h.recordValidator.ValidateRecord)
+		if err != nil {
+			outcome.Status = domain.RecordInvalid
+			outcome.Reason, outcome.Violations = domain.Rejection(err)
+			continue
+		}
+
+		// Records are deduplicated by requestId, so a retried batch only writes what is new
+		id := recordID(ctx, records[i].RequestID)
+		fresh, err := h.nonces.Reserve(ctx, id)
+		if err != nil {
+			h.logger.Error("failed to check record id", err)
+			releaseIDs(ctx, h.nonces, h.logger, reserved...)
+			return nil, storageError("failed to check record id", err)
+		}
+		if !fresh {
+			outcome.Status = domain.RecordDuplicate
+			continue
+		}
+
+		outcome.Status = domain.RecordAccepted
+		accepted = append(accepted, enriched)
+		reserved = append(reserved, id)
+	}
+
+	if len(accepted) > 0 {
+		if err := writeAll(ctx, h.writer, accepted); err != nil {
+			h.logger.Error("failed to write analytics batch", err)
+			releaseIDs(ctx, h.nonces, h.logger, reserved...)
+			return nil, storageError("failed to store analytics", err)
+		}
+	}
+
+	h.logger.Info("webhook batch processed",
+		"records", len(records),
+		"accepted", result.Count(domain.RecordAccepted),
+		"duplicate", result.Count(domain.RecordDuplicate),
+		"invalid", result.Count(domain.RecordInvalid))
+	return result, nil
+}
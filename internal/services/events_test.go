@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// MockRecordStore records updates and deletes
+type MockRecordStore struct {
+	MockAnalyticsWriter
+	Patches    []domain.AnalyticsRecord
+	Deleted    []string
+	Tombstones map[string]time.Time
+	Error      error
+}
+
+func (m *MockRecordStore) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Patches = append(m.Patches, patch)
+	return nil
+}
+
+func (m *MockRecordStore) Delete(ctx context.Context, requestID string) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Deleted = append(m.Deleted, requestID)
+	return nil
+}
+
+func (m *MockRecordStore) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	if m.Tombstones == nil {
+		m.Tombstones = make(map[string]time.Time)
+	}
+	m.Tombstones[requestID] = deletedAt
+	return nil
+}
+
+func TestEventRouterUnknownType(t *testing.T) {
+	// Arrange
+	router := NewDefaultEventRouter(&MockAnalyticsWriter{}, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{EventType: "analytics_record_archived"})
+
+	// Assert
+	if !errors.Is(err, domain.ErrUnknownEventType) {
+		t.Errorf("Expected ErrUnknownEventType, got %v", err)
+	}
+}
+
+func TestEventRouterRegisterCustomHandler(t *testing.T) {
+	// Arrange
+	router := NewDefaultEventRouter(&MockAnalyticsWriter{}, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	called := false
+	router.Register("analytics_record_archived", domain.EventHandlerFunc(func(ctx context.Context, payload *domain.WebhookPayload) (*domain.ProcessResult, error) {
+		called = true
+		return &domain.ProcessResult{}, nil
+	}))
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{EventType: "analytics_record_archived"})
+
+	// Assert
+	if err != nil || !called {
+		t.Errorf("Expected custom handler to run, got called=%v err=%v", called, err)
+	}
+}
+
+func TestUpdatedHandlerMergesSetFields(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{}
+	router := NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	payload := &domain.WebhookPayload{
+		EventType: domain.EventTypeUpdated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123", MatchScore: 80},
+	}
+
+	// Act
+	_, err := router.Handle(context.Background(), payload)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(store.Patches) != 1 {
+		t.Fatalf("Expected 1 patch, got %d", len(store.Patches))
+	}
+	fields := domain.PatchFields(store.Patches[0])
+	if len(fields) != 1 || fields["matchScore"] != 80 {
+		t.Errorf("Expected only matchScore in the patch, got %v", fields)
+	}
+}
+
+func TestUpdatedHandlerEnrichesSetFields(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{}
+	enrichers, _ := domain.NewEnrichers(domain.DefaultEnricherNames)
+	router := NewDefaultEventRouter(store, testRecordValidator, enrichers, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{
+		EventType: domain.EventTypeUpdated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123", Query: " Do you have Go? ", MatchScore: 90},
+	})
+
+	// Assert
+	if err != nil || len(store.Patches) != 1 {
+		t.Fatalf("Expected 1 patch, got %d (%v)", len(store.Patches), err)
+	}
+	fields := domain.PatchFields(store.Patches[0])
+	want := map[string]interface{}{"query": "Do you have Go?", "queryNormalized": "do you have go?", "matchScore": 90, "scoreBand": "high"}
+	if len(fields) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, fields)
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("Expected %s %v, got %v", name, value, fields[name])
+		}
+	}
+}
+
+func TestUpdatedHandlerRejectsQueryTrimmedToNothing(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{}
+	enrichers, _ := domain.NewEnrichers(domain.DefaultEnricherNames)
+	router := NewDefaultEventRouter(store, testRecordValidator, enrichers, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{
+		EventType: domain.EventTypeUpdated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123", Query: "   "},
+	})
+
+	// Assert
+	_, violations := domain.Rejection(err)
+	if len(violations) != 1 || violations[0].Pointer != "/query" || len(store.Patches) != 0 {
+		t.Errorf("Expected a /query violation and no update, got %v", err)
+	}
+}
+
+func TestCreatedHandlerRejectsQueryTrimmedToNothing(t *testing.T) {
+	// Arrange
+	writer := &MockAnalyticsWriter{}
+	enrichers, _ := domain.NewEnrichers(domain.DefaultEnricherNames)
+	router := NewDefaultEventRouter(writer, testRecordValidator, enrichers, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	raw := `{"requestId":"req_123","query":"   ","timestamp":1700000000}`
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{
+		EventType: domain.EventTypeCreated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123", Query: "   ", Timestamp: 1700000000, Raw: json.RawMessage(raw)},
+	})
+
+	// Assert
+	_, violations := domain.Rejection(err)
+	if len(violations) != 1 || violations[0].Pointer != "/query" || len(writer.WrittenRecords) != 0 {
+		t.Errorf("Expected a /query violation and no write, got %v", err)
+	}
+}
+
+func TestUpdatedHandlerAppliesRules(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{}
+	router := NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{
+		EventType: domain.EventTypeUpdated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123", MatchScore: -5},
+	})
+
+	// Assert
+	_, violations := domain.Rejection(err)
+	if len(violations) != 1 || violations[0].Rule != "matchScore-range" || len(store.Patches) != 0 {
+		t.Errorf("Expected matchScore-range to reject the update, got %v", err)
+	}
+}
+
+func TestUpdatedHandlerRejectsEmptyPatch(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{}
+	router := NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{
+		EventType: domain.EventTypeUpdated,
+		Data:      domain.AnalyticsRecord{RequestID: "req_123"},
+	})
+
+	// Assert
+	if err == nil || len(store.Patches) != 0 {
+		t.Errorf("Expected empty update to be rejected, got %v", err)
+	}
+}
+
+func TestDeletedHandlerModes(t *testing.T) {
+	payload := &domain.WebhookPayload{EventType: domain.EventTypeDeleted, Data: domain.AnalyticsRecord{RequestID: "req_123"}}
+
+	// Tombstone keeps the record
+	store := &MockRecordStore{}
+	router := NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	if _, err := router.Handle(context.Background(), payload); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := store.Tombstones["req_123"]; !ok || len(store.Deleted) != 0 {
+		t.Errorf("Expected a tombstone and no delete, got %v / %v", store.Tombstones, store.Deleted)
+	}
+
+	// Hard delete removes it
+	store = &MockRecordStore{}
+	router = NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteHard)
+	if _, err := router.Handle(context.Background(), payload); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(store.Deleted) != 1 || len(store.Tombstones) != 0 {
+		t.Errorf("Expected a delete and no tombstone, got %v / %v", store.Deleted, store.Tombstones)
+	}
+}
+
+func TestDeletedHandlerMissingRecord(t *testing.T) {
+	// Arrange
+	store := &MockRecordStore{Error: domain.ErrRecordNotFound}
+	router := NewDefaultEventRouter(store, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteHard)
+
+	// Act
+	_, err := router.Handle(context.Background(), &domain.WebhookPayload{EventType: domain.EventTypeDeleted, Data: domain.AnalyticsRecord{RequestID: "req_404"}})
+
+	// Assert
+	if !errors.Is(err, domain.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestCreatedHandlerAppliesRules(t *testing.T) {
+	// Arrange
+	writer := &MockAnalyticsWriter{}
+	router := NewDefaultEventRouter(writer, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	created := func(record domain.AnalyticsRecord) *domain.WebhookPayload {
+		return &domain.WebhookPayload{EventType: domain.EventTypeCreated, Data: record}
+	}
+
+	// Act
+	_, rejectErr := router.Handle(context.Background(), created(domain.AnalyticsRecord{RequestID: "r1", Query: "q", MatchScore: 150, Timestamp: 1698765432000}))
+	_, correctErr := router.Handle(context.Background(), created(domain.AnalyticsRecord{RequestID: "r2", Query: "q", Timestamp: 1698765432}))
+
+	// Assert
+	var validation *domain.ValidationError
+	if !errors.As(rejectErr, &validation) || validation.Violations[0].Rule != "matchScore-range" {
+		t.Errorf("Expected matchScore-range violation, got %v", rejectErr)
+	}
+	if correctErr != nil || len(writer.WrittenRecords) != 1 {
+		t.Fatalf("Expected corrected record to be stored, got %v", correctErr)
+	}
+	if stored := writer.WrittenRecords[0]; stored.Timestamp != 1698765432000 || stored.Week != "2023-W44" {
+		t.Errorf("Expected millisecond timestamp and derived week, got %+v", stored)
+	}
+}
+
+func TestHandlersEnrichValidRecordsOnly(t *testing.T) {
+	// Arrange
+	writer := &MockAnalyticsWriter{}
+	enricher := domain.Enrichers{domain.QueryNormalizer{}, domain.ScoreBandEnricher{Bands: domain.DefaultScoreBands}}
+	router := NewDefaultEventRouter(writer, testRecordValidator, enricher, &MockNonceStore{}, &MockLogger{}, DeleteTombstone)
+	batch := &domain.WebhookPayload{EventType: domain.EventTypeBatch, Records: []domain.AnalyticsRecord{
+		{RequestID: "r1", Query: " Go ", MatchScore: 85, Timestamp: 1698765432000},
+		{RequestID: "r2", Query: "", Timestamp: 1698765432000},
+	}}
+
+	// Act
+	result, err := router.Handle(context.Background(), batch)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Count(domain.RecordInvalid) != 1 || len(writer.WrittenRecords) != 1 {
+		t.Fatalf("Expected one stored and one invalid record, got %+v", result)
+	}
+	if stored := writer.WrittenRecords[0]; stored.Query != "Go" || stored.QueryNormalized != "go" || stored.ScoreBand != "high" {
+		t.Errorf("Expected the stored record to be enriched, got %+v", stored)
+	}
+}
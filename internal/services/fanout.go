@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// Sink is one named destination of a FanOutWriter
+// A Required sink that fails fails the operation; a best-effort sink that
+// fails is logged and queued for retry
+type Sink struct {
+	Name     string
+	Writer   domain.AnalyticsWriter
+	Required bool
+}
+
+// SinkError is the failure of one sink
+type SinkError struct {
+	Sink     string
+	Required bool
+	Err      error
+}
+
+// Error names the sink and whether it was best-effort
+func (e *SinkError) Error() string {
+	if e.Required {
+		return fmt.Sprintf("%s: %v", e.Sink, e.Err)
+	}
+	return fmt.Sprintf("%s (best-effort): %v", e.Sink, e.Err)
+}
+
+// Unwrap returns the sink's own error
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// FanOutError lists every sink that failed one operation
+// It is returned only when a required sink failed; sentinels such as
+// domain.ErrRecordNotFound still match with errors.Is
+type FanOutError struct {
+	Failures []*SinkError
+}
+
+// Error lists every failed sink
+func (e *FanOutError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.Error()
+	}
+	return "sinks failed: " + strings.Join(failures, "; ")
+}
+
+// Unwrap returns each failure, so errors.Is and errors.As see them all
+func (e *FanOutError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// FanOutWriter writes every record to all sinks concurrently, e.g. Firestore
+// for the dashboard plus an archive or reporting store
+// Best-effort failures go to retries, which may be nil to only log them
+type FanOutWriter struct {
+	sinks   []Sink
+	retries domain.RetryQueue
+	logger  domain.Logger
+}
+
+// NewFanOutWriter creates a writer over sinks
+func NewFanOutWriter(sinks []Sink, retries domain.RetryQueue, logger domain.Logger) *FanOutWriter {
+	return &FanOutWriter{sinks: sinks, retries: retries, logger: logger}
+}
+
+// Write implements domain.AnalyticsWriter
+func (w *FanOutWriter) Write(ctx context.Context, record domain.AnalyticsRecord) error {
+	return w.each(ctx, "write", func(ctx context.Context, sink Sink) error {
+		return sink.Writer.Write(ctx, record)
+	})
+}
+
+// WriteBatch implements domain.BatchWriter
+func (w *FanOutWriter) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	// Callers may reuse the slice (ingest does), but a queued retry still needs it
+	records = append([]domain.AnalyticsRecord(nil), records...)
+	return w.each(ctx, "writeBatch", func(ctx context.Context, sink Sink) error {
+		return writeAll(ctx, sink.Writer, records)
+	})
+}
+
+// Update implements domain.RecordUpdater
+func (w *FanOutWriter) Update(ctx context.Context, patch domain.AnalyticsRecord) error {
+	return w.each(ctx, "update", func(ctx context.Context, sink Sink) error {
+		updater, ok := sink.Writer.(domain.RecordUpdater)
+		if !ok {
+			return fmt.Errorf("analytics writer %T does not support updates", sink.Writer)
+		}
+		return updater.Update(ctx, patch)
+	})
+}
+
+// Delete implements domain.RecordDeleter
+func (w *FanOutWriter) Delete(ctx context.Context, requestID string) error {
+	return w.each(ctx, "delete", func(ctx context.Context, sink Sink) error {
+		deleter, ok := sink.Writer.(domain.RecordDeleter)
+		if !ok {
+			return fmt.Errorf("analytics writer %T does not support deletes", sink.Writer)
+		}
+		return deleter.Delete(ctx, requestID)
+	})
+}
+
+// Tombstone implements domain.RecordDeleter
+func (w *FanOutWriter) Tombstone(ctx context.Context, requestID string, deletedAt time.Time) error {
+	return w.each(ctx, "tombstone", func(ctx context.Context, sink Sink) error {
+		deleter, ok := sink.Writer.(domain.RecordDeleter)
+		if !ok {
+			return fmt.Errorf("analytics writer %T does not support deletes", sink.Writer)
+		}
+		return deleter.Tombstone(ctx, requestID, deletedAt)
+	})
+}
+
+// each runs op on every sink at once and waits for all of them
+// If a required sink failed, every failure is returned in one FanOutError and
+// nothing is queued: the sender retries the whole delivery, and a queued retry
+// would then write a second copy to sinks that append. Otherwise best-effort
+// failures are queued for retry
+func (w *FanOutWriter) each(ctx context.Context, operation string, op func(context.Context, Sink) error) error {
+	errs := make([]error, len(w.sinks))
+	var wg sync.WaitGroup
+	for i, sink := range w.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = op(ctx, sink)
+		}()
+	}
+	wg.Wait()
+
+	var failures []*SinkError
+	requiredFailed := false
+	for i, sink := range w.sinks {
+		if errs[i] == nil {
+			continue
+		}
+		failures = append(failures, &SinkError{Sink: sink.Name, Required: sink.Required, Err: errs[i]})
+		if sink.Required {
+			requiredFailed = true
+		}
+	}
+
+	if requiredFailed {
+		return &FanOutError{Failures: failures}
+	}
+	for i, sink := range w.sinks {
+		if errs[i] != nil {
+			w.retry(ctx, operation, sink, errs[i], op)
+		}
+	}
+	return nil
+}
+
+// retry logs a best-effort failure and queues op to run again on that sink
+// A record that is not stored stays missing, so not-found is not retried
+func (w *FanOutWriter) retry(ctx context.Context, operation string, sink Sink, err error, op func(context.Context, Sink) error) {
+	w.logger.Error("best-effort sink failed", fmt.Errorf("%s %s: %w", sink.Name, operation, err))
+	if w.retries == nil || errors.Is(err, domain.ErrRecordNotFound) {
+		return
+	}
+
+	job := domain.RetryJob{
+		Sink:      sink.Name,
+		Operation: operation,
+		Context:   context.WithoutCancel(ctx),
+		Run: func(ctx context.Context) error {
+			return op(ctx, sink)
+		},
+	}
+	if err := w.retries.Enqueue(job); err != nil {
+		w.logger.Error("failed to queue sink retry", fmt.Errorf("%s %s: %w", sink.Name, operation, err))
+	}
+}
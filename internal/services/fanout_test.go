@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// MockRetryQueue records queued jobs
+type MockRetryQueue struct {
+	Jobs []domain.RetryJob
+}
+
+func (m *MockRetryQueue) Enqueue(job domain.RetryJob) error {
+	m.Jobs = append(m.Jobs, job)
+	return nil
+}
+
+func TestFanOutWriterRequiredFailure(t *testing.T) {
+	// Arrange
+	first := &MockAnalyticsWriter{Error: errors.New("unavailable")}
+	second := &MockAnalyticsWriter{}
+	writer := NewFanOutWriter([]Sink{
+		{Name: "firestore", Writer: first, Required: true},
+		{Name: "archive", Writer: second, Required: true},
+	}, &MockRetryQueue{}, &MockLogger{})
+
+	// Act
+	err := writer.Write(context.Background(), domain.AnalyticsRecord{RequestID: "r1"})
+
+	// Assert
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Failures) != 1 || fanOutErr.Failures[0].Sink != "firestore" {
+		t.Fatalf("Expected a FanOutError naming firestore, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "firestore: unavailable") {
+		t.Errorf("Expected the failing sink in the message, got %q", err.Error())
+	}
+	if len(second.WrittenRecords) != 1 {
+		t.Errorf("Expected the second sink to be written despite the first failing, got %d", len(second.WrittenRecords))
+	}
+}
+
+func TestFanOutWriterBestEffortFailureIsQueued(t *testing.T) {
+	// Arrange
+	archive := &MockAnalyticsWriter{Error: errors.New("disk full")}
+	retries := &MockRetryQueue{}
+	logger := &MockLogger{}
+	writer := NewFanOutWriter([]Sink{
+		{Name: "firestore", Writer: &MockAnalyticsWriter{}, Required: true},
+		{Name: "archive", Writer: archive},
+	}, retries, logger)
+
+	// Act
+	err := writer.Write(context.Background(), domain.AnalyticsRecord{RequestID: "r1"})
+	archive.Error = nil
+	retryErr := retries.Jobs[0].Run(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Errorf("Expected a best-effort failure not to fail the write, got %v", err)
+	}
+	if len(logger.ErrorLogs) != 1 || len(retries.Jobs) != 1 || retries.Jobs[0].Sink != "archive" {
+		t.Fatalf("Expected one logged and queued failure, got %v and %+v", logger.ErrorLogs, retries.Jobs)
+	}
+	if retryErr != nil || len(archive.WrittenRecords) != 1 || archive.WrittenRecords[0].RequestID != "r1" {
+		t.Errorf("Expected the queued job to rewrite r1 to the archive, got %v (%v)", archive.WrittenRecords, retryErr)
+	}
+}
+
+func TestFanOutWriterDoesNotQueueWhenARequiredSinkFails(t *testing.T) {
+	// Arrange
+	archive := &MockAnalyticsWriter{Error: errors.New("disk full")}
+	retries := &MockRetryQueue{}
+	writer := NewFanOutWriter([]Sink{
+		{Name: "firestore", Writer: &MockAnalyticsWriter{Error: errors.New("unavailable")}, Required: true},
+		{Name: "archive", Writer: archive},
+	}, retries, &MockLogger{})
+
+	// Act
+	err := writer.Write(context.Background(), domain.AnalyticsRecord{RequestID: "r1"})
+
+	// Assert
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Failures) != 2 {
+		t.Fatalf("Expected a FanOutError naming both sinks, got %v", err)
+	}
+	if len(retries.Jobs) != 0 {
+		t.Errorf("Expected no retry while the sender redelivers, got %+v", retries.Jobs)
+	}
+}
+
+func TestFanOutWriterKeepsSentinels(t *testing.T) {
+	// Arrange
+	missing := &MockRecordStore{Error: domain.ErrRecordNotFound}
+	present := &MockRecordStore{}
+	retries := &MockRetryQueue{}
+	writer := NewFanOutWriter([]Sink{
+		{Name: "firestore", Writer: present, Required: true},
+		{Name: "rtdb", Writer: missing, Required: true},
+	}, retries, &MockLogger{})
+
+	// Act
+	err := writer.Delete(context.Background(), "r1")
+
+	// Assert
+	if !errors.Is(err, domain.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound through the aggregated error, got %v", err)
+	}
+	if len(present.Deleted) != 1 || len(retries.Jobs) != 0 {
+		t.Errorf("Expected the delete to reach the other sink and nothing queued, got %v and %d jobs", present.Deleted, len(retries.Jobs))
+	}
+}
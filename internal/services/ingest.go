@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// MaxIngestLineBytes bounds a single NDJSON line
+const MaxIngestLineBytes = 1 << 20
+
+// IngestService implements domain.StreamIngester for bulk NDJSON backfills
+// Lines are decoded and validated one at a time and written in chunks, so
+// memory use depends on the chunk size, not the body size
+type IngestService struct {
+	validator       domain.SignatureValidator
+	decoder         domain.StreamDecoder
+	redactor        domain.RecordRedactor
+	recordValidator domain.RecordValidator
+	enricher        domain.Enricher
+	schemas         *domain.SchemaVersions
+	mode            domain.DecodeMode
+	nonces          domain.NonceStore
+	writer          domain.AnalyticsWriter
+	logger          domain.Logger
+	chunkSize       int
+}
+
+// NewIngestService creates a new ingest service with dependency injection
+// The validator must authenticate from headers alone (see SignatureScheme.SignsBody),
+// since it runs before the body is read
+func NewIngestService(
+	validator domain.SignatureValidator,
+	decoder domain.StreamDecoder,
+	redactor domain.RecordRedactor,
+	recordValidator domain.RecordValidator,
+	enricher domain.Enricher,
+	nonces domain.NonceStore,
+	writer domain.AnalyticsWriter,
+	logger domain.Logger,
+	chunkSize int,
+	mode domain.DecodeMode,
+) *IngestService {
+	return &IngestService{
+		validator:       validator,
+		decoder:         decoder,
+		redactor:        redactor,
+		recordValidator: recordValidator,
+		enricher:        enricher,
+		schemas:         domain.NewDefaultSchemaVersions(),
+		mode:            mode,
+		nonces:          nonces,
+		writer:          writer,
+		logger:          logger,
+		chunkSize:       chunkSize,
+	}
+}
+
+// ingestLine accepts either a bare record or a webhook envelope with "data"
+// The record is decoded once its schema version is known
+type ingestLine struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// decodeLine decodes one NDJSON line, enforcing the decode mode
+// The record is returned with the error so a rejection can name its requestId
+func (s *IngestService) decodeLine(line []byte) (domain.AnalyticsRecord, error) {
+	if s.mode == domain.DecodeStrict {
+		if err := domain.CheckStrictJSON(line); err != nil {
+			return domain.AnalyticsRecord{}, err
+		}
+	}
+
+	var decoded ingestLine
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		return domain.AnalyticsRecord{}, fmt.Errorf("malformed JSON: %w", domain.DecodeError(err, ""))
+	}
+
+	raw, pointer := line, ""
+	var violations []domain.Violation
+	if len(decoded.Data) > 0 && string(decoded.Data) != "null" {
+		raw, pointer = decoded.Data, "/data"
+		envelope, err := domain.StrictEnvelopeViolations(s.mode, line)
+		if err != nil {
+			return domain.AnalyticsRecord{}, err
+		}
+		violations = envelope
+	}
+	record, err := s.schemas.DecodeRecord(raw, decoded.SchemaVersion)
+	if err != nil {
+		return domain.AnalyticsRecord{}, domain.PrefixViolations(err, pointer)
+	}
+
+	violations = append(violations, domain.StrictRecordViolations(s.mode, pointer, record)...)
+	if len(violations) > 0 {
+		return record, &domain.ValidationError{Violations: violations}
+	}
+	return record, nil
+}
+
+// Ingest authenticates the request, then streams body line by line
+// A requestId reserved in the nonce store within NONCE_TTL is counted as a
+// duplicate; that is the only deduplication on this path, so a resend after
+// the TTL (or to another instance with the memory store) writes the records
+// again, which Firestore overwrites but the Realtime Database appends
+func (s *IngestService) Ingest(ctx context.Context, body io.Reader, headers domain.Headers) (*domain.IngestSummary, error) {
+	if err := s.validator.Validate(nil, headers); err != nil {
+		s.logger.Error("ingest authentication failed", err)
+		return nil, fmt.Errorf("ingest authentication failed: %w", err)
+	}
+
+	// Compressed bodies are decoded as they are read, so a backfill is never buffered
+	summary := &domain.IngestSummary{}
+	decoded, err := s.decoder.DecodeStream(body, headers)
+	if err != nil {
+		s.logger.Error("failed to decode ingest body", err)
+		return summary, fmt.Errorf("failed to decode ingest body: %w", err)
+	}
+	defer decoded.Close()
+
+	chunk := make([]domain.AnalyticsRecord, 0, s.chunkSize)
+	reserved := make([]string, 0, s.chunkSize)
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := writeAll(ctx, s.writer, chunk); err != nil {
+			releaseIDs(ctx, s.nonces, s.logger, reserved...)
+			return storageError("failed to store analytics", err)
+		}
+		summary.Accepted += len(chunk)
+		chunk, reserved = chunk[:0], reserved[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(decoded)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxIngestLineBytes)
+	for scanner.Scan() {
+		summary.Lines++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		record, err := s.decodeLine(line)
+		if err != nil {
+			summary.Reject(summary.Lines, record.RequestID, err)
+			continue
+		}
+		s.redactor.Redact(&record)
+
+		if err := s.recordValidator.ValidateRecord(&record); err != nil {
+			summary.Reject(summary.Lines, record.RequestID, err)
+			continue
+		}
+		record, err = domain.EnrichRecord(record, s.enricher, s.recordValidator.ValidateRecord)
+		if err != nil {
+			summary.Reject(summary.Lines, record.RequestID, err)
+			continue
+		}
+
+		id := recordID(ctx, record.RequestID)
+		fresh, err := s.nonces.Reserve(ctx, id)
+		if err != nil {
+			s.logger.Error("failed to check record id", err)
+			releaseIDs(ctx, s.nonces, s.logger, reserved...)
+			return summary, storageError("failed to check record id", err)
+		}
+		if !fresh {
+			summary.Duplicate++
+			continue
+		}
+
+		chunk = append(chunk, record)
+		reserved = append(reserved, id)
+		if len(chunk) == s.chunkSize {
+			if err := flush(); err != nil {
+				s.logger.Error("failed to write ingest chunk", err)
+				return summary, err
+			}
+		}
+	}
+
+	// A read error or over-long line ends the stream; what was read so far is still stored
+	scanErr := scanner.Err()
+	if err := flush(); err != nil {
+		s.logger.Error("failed to write ingest chunk", err)
+		return summary, err
+	}
+	if scanErr != nil {
+		s.logger.Error("failed to read ingest stream", scanErr)
+		if errors.Is(scanErr, domain.ErrMalformedEncoding) || errors.Is(scanErr, domain.ErrDecodedTooLarge) {
+			return summary, fmt.Errorf("failed to decode ingest body at line %d: %w", summary.Lines+1, scanErr)
+		}
+		return summary, fmt.Errorf("%w: line %d: %v", domain.ErrInvalidPayload, summary.Lines+1, scanErr)
+	}
+
+	s.logger.Info("ingest completed",
+		"lines", summary.Lines,
+		"accepted", summary.Accepted,
+		"duplicate", summary.Duplicate,
+		"rejected", summary.Rejected,
+		"sender", domain.SenderFromContext(ctx))
+	return summary, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+func TestIngestServiceStreamsLines(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockBatchWriter{}
+	service := NewIngestService(validator, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 2, domain.DecodePermissive)
+
+	body := strings.Join([]string{
+		`{"requestId":"req_1","query":"q","timestamp":1700000000}`,
+		`{"eventType":"analytics_event","data":{"requestId":"req_2","query":"q","timestamp":1700000000}}`,
+		`{not json`,
+		``,
+		`{"requestId":"req_3","timestamp":1700000000}`,
+		`{"requestId":"req_1","query":"q","timestamp":1700000000}`,
+		`{"requestId":"req_4","query":"q","timestamp":1700000000}`,
+	}, "\n")
+
+	// Act
+	summary, err := service.Ingest(context.Background(), strings.NewReader(body), signedHeaders("token"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Lines != 7 || summary.Accepted != 3 || summary.Duplicate != 1 || summary.Rejected != 2 {
+		t.Errorf("Expected 7 lines, 3 accepted, 1 duplicate, 2 rejected, got %+v", summary)
+	}
+	if summary.Errors[0].Line != 3 || summary.Errors[1].Line != 5 || summary.Errors[1].Violations[0].Rule != "required" {
+		t.Errorf("Expected rejected lines 3 and 5 with reasons, got %+v", summary.Errors)
+	}
+	if writer.Batches != 2 {
+		t.Errorf("Expected 2 chunked writes, got %d", writer.Batches)
+	}
+}
+
+func TestIngestServiceDecodesCompressedBodies(t *testing.T) {
+	// Arrange
+	writer := &MockBatchWriter{}
+	service := NewIngestService(&MockSignatureValidator{ShouldValidate: true}, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 10, domain.DecodePermissive)
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(`{"requestId":"req_1","query":"q","timestamp":1700000000}` + "\n" + `{"requestId":"req_2","query":"q","timestamp":1700000000}`))
+	gz.Close()
+	headers := signedHeaders("token")
+	headers.Set(domain.ContentEncodingHeader, "gzip")
+
+	// Act
+	summary, err := service.Ingest(context.Background(), &body, headers)
+	headers.Set(domain.ContentEncodingHeader, "br")
+	_, unsupportedErr := service.Ingest(context.Background(), strings.NewReader("{}"), headers)
+
+	// Assert
+	if err != nil || summary.Accepted != 2 || summary.Rejected != 0 {
+		t.Errorf("Expected both gzipped lines to be accepted, got %+v (%v)", summary, err)
+	}
+	if !errors.Is(unsupportedErr, domain.ErrUnsupportedEncoding) {
+		t.Errorf("Expected an unknown coding to be rejected, got %v", unsupportedErr)
+	}
+}
+
+func TestIngestServiceAuthenticationFailure(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{Error: domain.ErrInvalidToken}
+	writer := &MockAnalyticsWriter{}
+	service := NewIngestService(validator, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 10, domain.DecodePermissive)
+
+	// Act
+	summary, err := service.Ingest(context.Background(), strings.NewReader(`{"requestId":"req_1","query":"q","timestamp":1}`), signedHeaders("token"))
+
+	// Assert
+	if summary != nil || !errors.Is(err, domain.ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken and no summary, got %v / %+v", err, summary)
+	}
+	if len(writer.WrittenRecords) != 0 {
+		t.Errorf("Expected nothing written, got %d records", len(writer.WrittenRecords))
+	}
+}
+
+func TestIngestServiceLineTooLong(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockAnalyticsWriter{}
+	service := NewIngestService(validator, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 10, domain.DecodePermissive)
+
+	body := `{"requestId":"req_1","query":"q","timestamp":1700000000}` + "\n" + strings.Repeat("x", MaxIngestLineBytes+1)
+
+	// Act
+	summary, err := service.Ingest(context.Background(), strings.NewReader(body), signedHeaders("token"))
+
+	// Assert: lines before the bad one are still stored
+	if !errors.Is(err, domain.ErrInvalidPayload) {
+		t.Errorf("Expected ErrInvalidPayload, got %v", err)
+	}
+	if summary == nil || summary.Accepted != 1 || len(writer.WrittenRecords) != 1 {
+		t.Errorf("Expected the first line to be stored, got %+v", summary)
+	}
+}
+
+func TestIngestServiceUpcastsLines(t *testing.T) {
+	// Arrange: v1 called the query "question"
+	schemas := domain.NewSchemaVersions(2)
+	schemas.Register(1, func(fields map[string]interface{}) error {
+		fields["query"] = fields["question"]
+		delete(fields, "question")
+		return nil
+	})
+	writer := &MockAnalyticsWriter{}
+	service := NewIngestService(&MockSignatureValidator{ShouldValidate: true}, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 10, domain.DecodePermissive)
+	service.schemas = schemas
+
+	body := strings.Join([]string{
+		`{"requestId":"req_1","question":"q","timestamp":1700000000}`,
+		`{"schemaVersion":2,"data":{"requestId":"req_2","query":"q","timestamp":1700000000}}`,
+		`{"requestId":"req_3","schemaVersion":3,"query":"q","timestamp":1700000000}`,
+	}, "\n")
+
+	// Act
+	summary, err := service.Ingest(context.Background(), strings.NewReader(body), signedHeaders("token"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Accepted != 2 || summary.Rejected != 1 || summary.Errors[0].Line != 3 {
+		t.Errorf("Expected 2 accepted and line 3 rejected, got %+v", summary)
+	}
+	if len(writer.WrittenRecords) != 2 || writer.WrittenRecords[0].Query != "q" || writer.WrittenRecords[0].SchemaVersion != 1 {
+		t.Errorf("Expected the v1 record upcast and stored with its version, got %+v", writer.WrittenRecords)
+	}
+}
+
+func TestIngestServiceStrictModeRejectsLines(t *testing.T) {
+	// Arrange
+	writer := &MockAnalyticsWriter{}
+	service := NewIngestService(&MockSignatureValidator{ShouldValidate: true}, testDecoder, testRedactor, testRecordValidator, domain.Enrichers{}, &MockNonceStore{}, writer, &MockLogger{}, 10, domain.DecodeStrict)
+
+	body := strings.Join([]string{
+		`{"requestId":"req_1","query":"q","timestamp":1700000000}`,
+		`{"requestId":"req_2","query":"q","timestamp":1700000000,"matchscore":80}`,
+		`{"eventType":"analytics_record_created","data":{"requestId":"req_3","query":"q","query":"r","timestamp":1700000000}}`,
+		`{"requestId":"req_4","query":"q","timestamp":1700000000} x`,
+	}, "\n")
+
+	// Act
+	summary, err := service.Ingest(context.Background(), strings.NewReader(body), signedHeaders("token"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if summary.Accepted != 1 || summary.Rejected != 3 {
+		t.Fatalf("Expected 1 accepted and 3 rejected, got %+v", summary)
+	}
+	want := []string{"/matchscore", "/data/query", ""}
+	for i, lineErr := range summary.Errors {
+		if len(lineErr.Violations) != 1 || lineErr.Violations[0].Pointer != want[i] {
+			t.Errorf("Line %d: expected a violation at %q, got %+v", lineErr.Line, want[i], lineErr.Violations)
+		}
+	}
+	if summary.Errors[0].RequestID != "req_2" {
+		t.Errorf("Expected the rejection to name req_2, got %q", summary.Errors[0].RequestID)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+// Defaults for NewMemoryRetryQueue
+const (
+	DefaultRetryCapacity    = 1000
+	DefaultRetryMaxAttempts = 5
+	DefaultRetryBackoff     = 2 * time.Second
+)
+
+// retryTimeout bounds a single retry so one hung sink cannot stall the queue
+const retryTimeout = 10 * time.Second
+
+// MemoryRetryQueue implements domain.RetryQueue in process memory
+// A background goroutine re-runs due jobs, doubling the wait after each
+// failure, and drops a job once its retries have all failed
+// Jobs are lost on restart, so it only suits best-effort sinks, and it needs
+// CPU between requests, which Cloud Functions do not give it
+type MemoryRetryQueue struct {
+	capacity    int
+	maxAttempts int
+	backoff     time.Duration
+	logger      domain.Logger
+	mu          sync.Mutex
+	pending     []*pendingRetry
+	now         func() time.Time
+	stop        chan struct{}
+	once        sync.Once
+}
+
+// pendingRetry is a queued job and when it is next due
+type pendingRetry struct {
+	job      domain.RetryJob
+	attempts int
+	due      time.Time
+}
+
+// NewMemoryRetryQueue creates a queue holding at most capacity jobs, each
+// retried up to maxAttempts times, first after backoff
+func NewMemoryRetryQueue(capacity, maxAttempts int, backoff time.Duration, logger domain.Logger) *MemoryRetryQueue {
+	q := &MemoryRetryQueue{
+		capacity:    capacity,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		logger:      logger,
+		now:         time.Now,
+		stop:        make(chan struct{}),
+	}
+	go q.retryLoop(backoff)
+	return q
+}
+
+// Enqueue implements domain.RetryQueue
+func (q *MemoryRetryQueue) Enqueue(job domain.RetryJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) >= q.capacity {
+		return fmt.Errorf("%w: %d jobs pending", domain.ErrRetryQueueFull, len(q.pending))
+	}
+	q.pending = append(q.pending, &pendingRetry{job: job, due: q.now().Add(q.backoff)})
+	return nil
+}
+
+// Len reports how many jobs are waiting
+func (q *MemoryRetryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.pending)
+}
+
+// Close stops the retry goroutine; jobs still pending are dropped
+func (q *MemoryRetryQueue) Close() {
+	q.once.Do(func() { close(q.stop) })
+}
+
+// retryLoop periodically runs the jobs that are due
+func (q *MemoryRetryQueue) retryLoop(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.retryDue(context.Background())
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// retryDue runs every job whose wait has passed, under the job's own context
+// when it has one. Jobs run outside the lock so a slow sink does not block Enqueue
+func (q *MemoryRetryQueue) retryDue(ctx context.Context) {
+	q.mu.Lock()
+	now := q.now()
+	var due []*pendingRetry
+	waiting := q.pending[:0]
+	for _, p := range q.pending {
+		if now.Before(p.due) {
+			waiting = append(waiting, p)
+		} else {
+			due = append(due, p)
+		}
+	}
+	q.pending = waiting
+	q.mu.Unlock()
+
+	for _, p := range due {
+		parent := p.job.Context
+		if parent == nil {
+			parent = ctx
+		}
+		attemptCtx, cancel := context.WithTimeout(parent, retryTimeout)
+		err := p.job.Run(attemptCtx)
+		cancel()
+
+		p.attempts++
+		if err == nil {
+			q.logger.Info("Retried sink write succeeded", "sink", p.job.Sink, "operation", p.job.Operation, "attempts", p.attempts)
+			continue
+		}
+		if p.attempts >= q.maxAttempts {
+			q.logger.Error("dropping sink write after retries", fmt.Errorf("%s %s failed %d times: %w", p.job.Sink, p.job.Operation, p.attempts, err))
+			continue
+		}
+
+		p.due = q.now().Add(q.backoff << p.attempts)
+		q.mu.Lock()
+		q.pending = append(q.pending, p)
+		q.mu.Unlock()
+	}
+}
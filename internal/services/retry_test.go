@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/webhook-receiver/internal/domain"
+)
+
+func newTestRetryQueue(capacity, maxAttempts int, logger domain.Logger) (*MemoryRetryQueue, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	queue := NewMemoryRetryQueue(capacity, maxAttempts, time.Hour, logger)
+	queue.now = func() time.Time { return now }
+	return queue, &now
+}
+
+func TestMemoryRetryQueueRetriesWithBackoff(t *testing.T) {
+	// Arrange
+	queue, now := newTestRetryQueue(10, 3, &MockLogger{})
+	defer queue.Close()
+	runs := 0
+	_ = queue.Enqueue(domain.RetryJob{Sink: "archive", Operation: "write", Run: func(ctx context.Context) error {
+		runs++
+		if runs == 1 {
+			return errors.New("still down")
+		}
+		return nil
+	}})
+
+	// Act
+	queue.retryDue(context.Background()) // not due yet
+	*now = now.Add(time.Hour)
+	queue.retryDue(context.Background()) // fails, waits two hours
+	*now = now.Add(time.Hour)
+	queue.retryDue(context.Background()) // not due yet
+	*now = now.Add(time.Hour)
+	queue.retryDue(context.Background()) // succeeds
+
+	// Assert
+	if runs != 2 || queue.Len() != 0 {
+		t.Errorf("Expected two runs and an empty queue, got %d runs and %d pending", runs, queue.Len())
+	}
+}
+
+// tenantRecordingWriter records the tenant each write ran for
+type tenantRecordingWriter struct {
+	Tenants []string
+	Error   error
+}
+
+func (w *tenantRecordingWriter) Write(ctx context.Context, record domain.AnalyticsRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.Tenants = append(w.Tenants, domain.TenantIDFromContext(ctx))
+	return w.Error
+}
+
+func TestMemoryRetryQueueKeepsTheTenant(t *testing.T) {
+	// Arrange: the request that failed has returned and cancelled its context
+	queue, now := newTestRetryQueue(10, 3, &MockLogger{})
+	defer queue.Close()
+	archive := &tenantRecordingWriter{Error: errors.New("disk full")}
+	writer := NewFanOutWriter([]Sink{
+		{Name: "firestore", Writer: &MockAnalyticsWriter{}, Required: true},
+		{Name: "archive", Writer: archive},
+	}, queue, &MockLogger{})
+	ctx, cancel := context.WithCancel(domain.WithTenant(context.Background(), &domain.Tenant{ID: "blog"}))
+	_ = writer.Write(ctx, domain.AnalyticsRecord{RequestID: "r1"})
+	cancel()
+	archive.Error = nil
+
+	// Act
+	*now = now.Add(time.Hour)
+	queue.retryDue(context.Background())
+
+	// Assert
+	if len(archive.Tenants) != 2 || archive.Tenants[1] != "blog" || queue.Len() != 0 {
+		t.Errorf("Expected the retry written for tenant blog, got %v and %d pending", archive.Tenants, queue.Len())
+	}
+}
+
+func TestMemoryRetryQueueDropsAfterMaxAttempts(t *testing.T) {
+	// Arrange
+	logger := &MockLogger{}
+	queue, now := newTestRetryQueue(10, 2, logger)
+	defer queue.Close()
+	_ = queue.Enqueue(domain.RetryJob{Sink: "archive", Operation: "write", Run: func(ctx context.Context) error {
+		return errors.New("gone")
+	}})
+
+	// Act
+	for i := 0; i < 4; i++ {
+		*now = now.Add(4 * time.Hour)
+		queue.retryDue(context.Background())
+	}
+
+	// Assert
+	if queue.Len() != 0 || len(logger.ErrorLogs) != 1 {
+		t.Errorf("Expected the job dropped and logged once, got %d pending and %v", queue.Len(), logger.ErrorLogs)
+	}
+}
+
+func TestMemoryRetryQueueFull(t *testing.T) {
+	// Arrange
+	queue, _ := newTestRetryQueue(1, 3, &MockLogger{})
+	defer queue.Close()
+	job := domain.RetryJob{Sink: "archive", Operation: "write", Run: func(ctx context.Context) error { return nil }}
+
+	// Act
+	first := queue.Enqueue(job)
+	second := queue.Enqueue(job)
+
+	// Assert
+	if first != nil || !errors.Is(second, domain.ErrRetryQueueFull) {
+		t.Errorf("Expected the second job to be refused, got %v and %v", first, second)
+	}
+}
@@ -2,7 +2,7 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 
 	"example.com/webhook-receiver/internal/domain"
@@ -12,64 +12,150 @@ import (
 // Orchestrates validation and storage (Business Logic Layer)
 type WebhookService struct {
 	validator domain.SignatureValidator
-	writer    domain.AnalyticsWriter
+	decoder   domain.BodyDecoder
+	parser    domain.PayloadParser
+	redactor  domain.RecordRedactor
+	nonces    domain.NonceStore
+	events    domain.EventHandler
 	logger    domain.Logger
 }
 
 // NewWebhookService creates a new webhook service with dependency injection
+// The validator sees the body as sent; the decoder runs only after it passes,
+// so unauthenticated bodies are never decompressed. The redactor runs right
+// after parsing, so no later step logs or stores the original text. events
+// applies the parsed payload to storage, usually a domain.EventRouter from
+// NewDefaultEventRouter
 func NewWebhookService(
 	validator domain.SignatureValidator,
-	writer domain.AnalyticsWriter,
+	decoder domain.BodyDecoder,
+	parser domain.PayloadParser,
+	redactor domain.RecordRedactor,
+	nonces domain.NonceStore,
+	events domain.EventHandler,
 	logger domain.Logger,
 ) *WebhookService {
 	return &WebhookService{
 		validator: validator,
-		writer:    writer,
+		decoder:   decoder,
+		parser:    parser,
+		redactor:  redactor,
+		nonces:    nonces,
+		events:    events,
 		logger:    logger,
 	}
 }
 
 // Process validates and stores the webhook payload
-func (s *WebhookService) Process(ctx context.Context, payload []byte, signature string) error {
-	// Step 1: Validate signature
-	if err := s.validator.Validate(payload, signature); err != nil {
+func (s *WebhookService) Process(ctx context.Context, payload []byte, headers domain.Headers) (*domain.ProcessResult, error) {
+	// Step 1: Validate signature and replay window
+	if err := s.validator.Validate(payload, headers); err != nil {
 		s.logger.Error("webhook validation failed", err)
-		return fmt.Errorf("webhook validation failed: %w", err)
+		return nil, fmt.Errorf("webhook validation failed: %w", err)
 	}
 
-	// Step 2: Parse payload
-	var webhookPayload domain.WebhookPayload
-	if err := json.Unmarshal(payload, &webhookPayload); err != nil {
+	// Step 2: Reject duplicate deliveries of the same signed message
+	messageID := scopedID(ctx, domain.MessageID(payload, headers, s.validator))
+	fresh, err := s.nonces.Reserve(ctx, messageID)
+	if err != nil {
+		s.logger.Error("failed to check message id", err)
+		return nil, storageError("failed to check message id", err)
+	}
+	if !fresh {
+		s.logger.Info("duplicate delivery ignored", "messageId", messageID, "sender", domain.SenderFromContext(ctx))
+		return nil, domain.ErrDuplicateDelivery
+	}
+
+	// Release the message ID on failure so the sender's retry is not treated as a replay
+	result, err := s.handle(ctx, payload, headers)
+	if err != nil {
+		releaseIDs(ctx, s.nonces, s.logger, messageID)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// handle parses an authenticated payload and dispatches it by event type
+func (s *WebhookService) handle(ctx context.Context, payload []byte, headers domain.Headers) (*domain.ProcessResult, error) {
+	// Step 3: Decode the signed bytes (e.g. decompress) and parse the negotiated format
+	payload, err := s.decoder.Decode(payload, headers)
+	if err != nil {
+		s.logger.Error("failed to decode webhook body", err)
+		return nil, fmt.Errorf("failed to decode webhook body: %w", err)
+	}
+
+	webhookPayload, err := s.parser.Parse(payload, headers)
+	if err != nil {
 		s.logger.Error("failed to parse webhook payload", err)
-		return fmt.Errorf("failed to parse webhook: %w", err)
+		return nil, fmt.Errorf("failed to parse webhook: %w", err)
 	}
 
-	// Step 3: Validate parsed data
-	if err := validateAnalyticsRecord(&webhookPayload.Data); err != nil {
-		s.logger.Error("analytics record validation failed", err)
-		return fmt.Errorf("invalid analytics record: %w", err)
+	// Step 4: Redact personal data before anything else sees the records
+	s.redactor.Redact(&webhookPayload.Data)
+	for i := range webhookPayload.Records {
+		s.redactor.Redact(&webhookPayload.Records[i])
 	}
 
-	// Step 4: Store in Firebase
-	if err := s.writer.Write(ctx, webhookPayload.Data); err != nil {
-		s.logger.Error("failed to write analytics", err)
-		return fmt.Errorf("failed to store analytics: %w", err)
+	// Step 5: Validate and store according to the event type
+	result, err := s.events.Handle(ctx, webhookPayload)
+	if err != nil {
+		s.logger.Error("failed to handle webhook event", err)
+		return nil, err
 	}
 
-	s.logger.Info("webhook processed successfully", "requestId", webhookPayload.Data.RequestID)
+	// Sender is set when the transport authenticated the client (mTLS); the
+	// schema version shows which senders still need upgrading
+	s.logger.Info("webhook processed successfully",
+		"eventType", webhookPayload.EventType,
+		"requestId", webhookPayload.Data.RequestID,
+		"schemaVersion", webhookPayload.SchemaVersion,
+		"tenant", domain.TenantIDFromContext(ctx),
+		"sender", domain.SenderFromContext(ctx))
+	return result, nil
+}
+
+// writeAll stores records in one call when the writer supports batches
+func writeAll(ctx context.Context, writer domain.AnalyticsWriter, records []domain.AnalyticsRecord) error {
+	if batchWriter, ok := writer.(domain.BatchWriter); ok {
+		return batchWriter.WriteBatch(ctx, records)
+	}
+	for _, record := range records {
+		if err := writer.Write(ctx, record); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// validateAnalyticsRecord ensures required fields are present
-func validateAnalyticsRecord(record *domain.AnalyticsRecord) error {
-	if record.RequestID == "" {
-		return fmt.Errorf("requestId is required")
+// storageError wraps a failure of the writer or nonce store in
+// domain.ErrDatabaseWrite, so the sender is told to retry rather than that it
+// sent something wrong. A missing record is the sender's mistake and is kept as it is
+func storageError(action string, err error) error {
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		return fmt.Errorf("%s: %w", action, err)
 	}
-	if record.Query == "" {
-		return fmt.Errorf("query is required")
+	return fmt.Errorf("%s: %w: %w", action, domain.ErrDatabaseWrite, err)
+}
+
+// scopedID prefixes id with the tenant, since IDs are only unique per sender
+func scopedID(ctx context.Context, id string) string {
+	if tenantID := domain.TenantIDFromContext(ctx); tenantID != "" {
+		return tenantID + "/" + id
 	}
-	if record.Timestamp == 0 {
-		return fmt.Errorf("timestamp is required")
+	return id
+}
+
+// recordID is the nonce key that deduplicates a record by its requestId
+func recordID(ctx context.Context, requestID string) string {
+	return scopedID(ctx, "record:"+requestID)
+}
+
+// releaseIDs forgets reserved IDs after a failure so the sender can retry
+func releaseIDs(ctx context.Context, nonces domain.NonceStore, logger domain.Logger, ids ...string) {
+	for _, id := range ids {
+		if err := nonces.Release(ctx, id); err != nil {
+			logger.Error("failed to release message id", err)
+		}
 	}
-	return nil
 }
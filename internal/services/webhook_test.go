@@ -1,9 +1,18 @@
 package services
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
 	"testing"
+	"time"
 
 	"example.com/webhook-receiver/internal/domain"
 )
@@ -12,15 +21,20 @@ import (
 type MockSignatureValidator struct {
 	ShouldValidate bool
 	Error          error
+	SignedID       string // delivery ID the mock scheme claims to authenticate
 }
 
-func (m *MockSignatureValidator) Validate(payload []byte, signature string) error {
+func (m *MockSignatureValidator) Validate(payload []byte, headers domain.Headers) error {
 	if m.Error != nil {
 		return m.Error
 	}
 	return nil
 }
 
+func (m *MockSignatureValidator) MessageID(headers domain.Headers) string {
+	return m.SignedID
+}
+
 // MockAnalyticsWriter for testing
 type MockAnalyticsWriter struct {
 	WrittenRecords []domain.AnalyticsRecord
@@ -35,6 +49,29 @@ func (m *MockAnalyticsWriter) Write(ctx context.Context, record domain.Analytics
 	return nil
 }
 
+// MockNonceStore for testing
+type MockNonceStore struct {
+	Seen     map[string]bool
+	Released []string
+}
+
+func (m *MockNonceStore) Reserve(ctx context.Context, id string) (bool, error) {
+	if m.Seen == nil {
+		m.Seen = make(map[string]bool)
+	}
+	if m.Seen[id] {
+		return false, nil
+	}
+	m.Seen[id] = true
+	return true, nil
+}
+
+func (m *MockNonceStore) Release(ctx context.Context, id string) error {
+	delete(m.Seen, id)
+	m.Released = append(m.Released, id)
+	return nil
+}
+
 // MockLogger for testing
 type MockLogger struct {
 	ErrorLogs []string
@@ -54,15 +91,57 @@ func (m *MockLogger) Debug(msg string, args ...interface{}) {
 	m.DebugLogs = append(m.DebugLogs, msg)
 }
 
+// testDecoder decodes bodies as the production service would
+var testDecoder = domain.NewContentDecoder(domain.DefaultMaxDecodedBytes)
+
+// testParser negotiates payload formats as the production service would
+var testParser = domain.NewDefaultPayloadFormats(domain.DecodePermissive)
+
+// testRecordValidator applies the default schema and rules, as the production service does
+var testRecordValidator = func() domain.RecordValidator {
+	schema, err := domain.NewJSONSchemaValidator(domain.DefaultRecordSchema)
+	if err != nil {
+		panic(err)
+	}
+	rules, err := domain.ParseRules(domain.DefaultRules)
+	if err != nil {
+		panic(err)
+	}
+	return domain.RecordValidators{schema, domain.NewRuleSet(rules, &MockLogger{}), schema}
+}()
+
+// testRedactor masks the default detectors, as the production service does
+var testRedactor = func() domain.RecordRedactor {
+	redactor, err := domain.NewRedactor(domain.DefaultDetectors(), domain.RedactMask, nil, &MockLogger{})
+	if err != nil {
+		panic(err)
+	}
+	return redactor
+}()
+
+// newTestService wires a service with the default formats and event handlers
+func newTestService(validator domain.SignatureValidator, nonces domain.NonceStore, writer domain.AnalyticsWriter, logger domain.Logger) *WebhookService {
+	events := NewDefaultEventRouter(writer, testRecordValidator, domain.Enrichers{}, nonces, logger, DeleteTombstone)
+	return NewWebhookService(validator, testDecoder, testParser, testRedactor, nonces, events, logger)
+}
+
+// signedHeaders builds request headers carrying the given signature
+func signedHeaders(signature string) http.Header {
+	headers := http.Header{}
+	headers.Set(domain.SignatureHeader, signature)
+	headers.Set(domain.TimestampHeader, "1700000000")
+	return headers
+}
+
 func TestWebhookServiceProcessSuccess(t *testing.T) {
 	// Arrange
 	validator := &MockSignatureValidator{ShouldValidate: true}
 	writer := &MockAnalyticsWriter{}
 	logger := &MockLogger{}
-	service := NewWebhookService(validator, writer, logger)
+	service := newTestService(validator, &MockNonceStore{}, writer, logger)
 
 	payload := domain.WebhookPayload{
-		EventType: "analytics_event",
+		EventType: domain.EventTypeCreated,
 		Timestamp: 1700000000,
 		Data: domain.AnalyticsRecord{
 			RequestID: "req_123",
@@ -74,7 +153,7 @@ func TestWebhookServiceProcessSuccess(t *testing.T) {
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Act
-	err := service.Process(context.Background(), payloadJSON, "valid_signature")
+	_, err := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
 
 	// Assert
 	if err != nil {
@@ -96,12 +175,12 @@ func TestWebhookServiceProcessInvalidJSON(t *testing.T) {
 	validator := &MockSignatureValidator{ShouldValidate: true}
 	writer := &MockAnalyticsWriter{}
 	logger := &MockLogger{}
-	service := NewWebhookService(validator, writer, logger)
+	service := newTestService(validator, &MockNonceStore{}, writer, logger)
 
 	invalidJSON := []byte("{invalid json")
 
 	// Act
-	err := service.Process(context.Background(), invalidJSON, "valid_signature")
+	_, err := service.Process(context.Background(), invalidJSON, signedHeaders("valid_signature"))
 
 	// Assert
 	if err == nil {
@@ -112,16 +191,35 @@ func TestWebhookServiceProcessInvalidJSON(t *testing.T) {
 	}
 }
 
+func TestWebhookServiceProcessWrongTypeIsAViolation(t *testing.T) {
+	// Arrange
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(&MockSignatureValidator{ShouldValidate: true}, &MockNonceStore{}, writer, &MockLogger{})
+	body := []byte(`{"eventType":"analytics_record_created","data":{"requestId":"r1","query":"q","matchScore":"95","timestamp":1700000000}}`)
+
+	// Act
+	_, err := service.Process(context.Background(), body, signedHeaders("valid_signature"))
+
+	// Assert
+	var validation *domain.ValidationError
+	if !errors.As(err, &validation) || validation.Violations[0].Pointer != "/data/matchScore" {
+		t.Errorf("Expected a ValidationError at /data/matchScore, got %v", err)
+	}
+	if len(writer.WrittenRecords) != 0 {
+		t.Errorf("Expected 0 written records, got %d", len(writer.WrittenRecords))
+	}
+}
+
 func TestWebhookServiceProcessMissingRequired(t *testing.T) {
 	// Arrange
 	validator := &MockSignatureValidator{ShouldValidate: true}
 	writer := &MockAnalyticsWriter{}
 	logger := &MockLogger{}
-	service := NewWebhookService(validator, writer, logger)
+	service := newTestService(validator, &MockNonceStore{}, writer, logger)
 
 	// Payload missing RequestID (required)
 	payload := domain.WebhookPayload{
-		EventType: "analytics_event",
+		EventType: domain.EventTypeCreated,
 		Timestamp: 1700000000,
 		Data: domain.AnalyticsRecord{
 			Query:     "test query",
@@ -132,7 +230,7 @@ func TestWebhookServiceProcessMissingRequired(t *testing.T) {
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Act
-	err := service.Process(context.Background(), payloadJSON, "valid_signature")
+	_, err := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
 
 	// Assert
 	if err == nil {
@@ -148,10 +246,10 @@ func TestWebhookServiceProcessSignatureValidationFailure(t *testing.T) {
 	validator := &MockSignatureValidator{Error: domain.ErrInvalidSignature}
 	writer := &MockAnalyticsWriter{}
 	logger := &MockLogger{}
-	service := NewWebhookService(validator, writer, logger)
+	service := newTestService(validator, &MockNonceStore{}, writer, logger)
 
 	payload := domain.WebhookPayload{
-		EventType: "analytics_event",
+		EventType: domain.EventTypeCreated,
 		Timestamp: 1700000000,
 		Data: domain.AnalyticsRecord{
 			RequestID: "req_123",
@@ -163,7 +261,7 @@ func TestWebhookServiceProcessSignatureValidationFailure(t *testing.T) {
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Act
-	err := service.Process(context.Background(), payloadJSON, "invalid_signature")
+	_, err := service.Process(context.Background(), payloadJSON, signedHeaders("invalid_signature"))
 
 	// Assert
 	if err == nil {
@@ -179,10 +277,10 @@ func TestWebhookServiceProcessWriterFailure(t *testing.T) {
 	validator := &MockSignatureValidator{ShouldValidate: true}
 	writer := &MockAnalyticsWriter{Error: domain.ErrDatabaseWrite}
 	logger := &MockLogger{}
-	service := NewWebhookService(validator, writer, logger)
+	service := newTestService(validator, &MockNonceStore{}, writer, logger)
 
 	payload := domain.WebhookPayload{
-		EventType: "analytics_event",
+		EventType: domain.EventTypeCreated,
 		Timestamp: 1700000000,
 		Data: domain.AnalyticsRecord{
 			RequestID: "req_123",
@@ -194,7 +292,7 @@ func TestWebhookServiceProcessWriterFailure(t *testing.T) {
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Act
-	err := service.Process(context.Background(), payloadJSON, "valid_signature")
+	_, err := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
 
 	// Assert
 	if err == nil {
@@ -204,3 +302,380 @@ func TestWebhookServiceProcessWriterFailure(t *testing.T) {
 		t.Errorf("Expected error logs, got none")
 	}
 }
+
+func TestWebhookServiceProcessMarksStorageFailures(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockAnalyticsWriter{Error: errors.New("firestore: unavailable")}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+	payloadJSON := []byte(`{"eventType":"analytics_record_created","timestamp":1700000000,"data":{"requestId":"req_123","query":"test query","timestamp":1700000000}}`)
+
+	// Act
+	_, err := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
+
+	// Assert
+	if !errors.Is(err, domain.ErrDatabaseWrite) {
+		t.Errorf("Expected a sink failure to be reported as ErrDatabaseWrite, got %v", err)
+	}
+}
+
+func TestWebhookServiceProcessDuplicateDelivery(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	nonces := &MockNonceStore{}
+	writer := &MockAnalyticsWriter{}
+	logger := &MockLogger{}
+	service := newTestService(validator, nonces, writer, logger)
+
+	payload := domain.WebhookPayload{
+		EventType: domain.EventTypeCreated,
+		Timestamp: 1700000000,
+		Data: domain.AnalyticsRecord{
+			RequestID: "req_123",
+			Query:     "test query",
+			SessionID: "sess_789",
+			Timestamp: 1700000000,
+		},
+	}
+	payloadJSON, _ := json.Marshal(payload)
+
+	// Act
+	_, first := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
+	_, second := service.Process(context.Background(), payloadJSON, signedHeaders("valid_signature"))
+
+	// Assert
+	if first != nil {
+		t.Errorf("Expected first delivery to succeed, got %v", first)
+	}
+	if !errors.Is(second, domain.ErrDuplicateDelivery) {
+		t.Errorf("Expected ErrDuplicateDelivery, got %v", second)
+	}
+	if len(writer.WrittenRecords) != 1 {
+		t.Errorf("Expected 1 written record, got %d", len(writer.WrittenRecords))
+	}
+}
+
+func TestWebhookServiceProcessIgnoresUnsignedMessageID(t *testing.T) {
+	// Arrange: a scheme that does not sign X-Webhook-Id
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	payloadJSON := []byte(`{"eventType":"analytics_record_created","data":{"requestId":"req_123","query":"q","timestamp":1700000000}}`)
+	original := signedHeaders("valid_signature")
+	original.Set("X-Webhook-Id", "msg_1")
+	replay := signedHeaders("valid_signature")
+	replay.Set("X-Webhook-Id", "msg_2")
+
+	// Act
+	_, first := service.Process(context.Background(), payloadJSON, original)
+	_, second := service.Process(context.Background(), payloadJSON, replay)
+
+	// Assert: a replay with a new unsigned ID is still a duplicate
+	if first != nil || !errors.Is(second, domain.ErrDuplicateDelivery) {
+		t.Errorf("Expected the replay to be a duplicate, got %v and %v", first, second)
+	}
+	if len(writer.WrittenRecords) != 1 {
+		t.Errorf("Expected 1 written record, got %d", len(writer.WrittenRecords))
+	}
+}
+
+func TestWebhookServiceProcessReleasesMessageIDOnFailure(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true, SignedID: "msg_1"}
+	nonces := &MockNonceStore{}
+	writer := &MockAnalyticsWriter{Error: domain.ErrDatabaseWrite}
+	logger := &MockLogger{}
+	service := newTestService(validator, nonces, writer, logger)
+
+	payloadJSON := []byte(`{"eventType":"analytics_record_created","data":{"requestId":"req_123","query":"q","timestamp":1700000000}}`)
+	headers := signedHeaders("valid_signature")
+
+	// Act
+	_, err := service.Process(context.Background(), payloadJSON, headers)
+
+	// Assert: the sender may retry the same message ID and record
+	if err == nil {
+		t.Errorf("Expected database write error, got nil")
+	}
+	if len(nonces.Released) != 2 || nonces.Released[0] != "record:req_123" || nonces.Released[1] != "msg_1" {
+		t.Errorf("Expected record:req_123 and msg_1 to be released, got %v", nonces.Released)
+	}
+}
+
+func TestWebhookServiceProcessSingleThenBatchWritesOnce(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	record := domain.AnalyticsRecord{RequestID: "req_1", Query: "q", Timestamp: 1700000000}
+	single, _ := json.Marshal(domain.WebhookPayload{EventType: domain.EventTypeCreated, Timestamp: 1700000000, Data: record})
+
+	// Act
+	_, first := service.Process(context.Background(), single, signedHeaders("valid_signature"))
+	result, second := service.Process(context.Background(), batchPayload(record), signedHeaders("valid_signature"))
+
+	// Assert
+	if first != nil || second != nil {
+		t.Fatalf("Expected no errors, got %v and %v", first, second)
+	}
+	if result.Count(domain.RecordDuplicate) != 1 || len(writer.WrittenRecords) != 1 {
+		t.Errorf("Expected the batch copy to be a duplicate and one write, got %+v and %d writes", result.Records, len(writer.WrittenRecords))
+	}
+}
+
+func TestWebhookServiceProcessScopesMessageIDsByTenant(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true, SignedID: "msg_1"}
+	nonces := &MockNonceStore{}
+	writer := &MockAnalyticsWriter{}
+	logger := &MockLogger{}
+	service := newTestService(validator, nonces, writer, logger)
+
+	payloadJSON := []byte(`{"eventType":"analytics_record_created","data":{"requestId":"req_123","query":"q","timestamp":1700000000}}`)
+	headers := signedHeaders("valid_signature")
+
+	portfolio := domain.WithTenant(context.Background(), &domain.Tenant{ID: "portfolio"})
+	blog := domain.WithTenant(context.Background(), &domain.Tenant{ID: "blog"})
+
+	// Act
+	_, first := service.Process(portfolio, payloadJSON, headers)
+	_, second := service.Process(blog, payloadJSON, headers)
+
+	// Assert: senders cannot collide on each other's message IDs
+	if first != nil || second != nil {
+		t.Errorf("Expected both tenants to succeed, got %v and %v", first, second)
+	}
+	if !nonces.Seen["portfolio/msg_1"] || !nonces.Seen["blog/msg_1"] {
+		t.Errorf("Expected tenant-scoped message IDs, got %v", nonces.Seen)
+	}
+	if len(writer.WrittenRecords) != 2 {
+		t.Errorf("Expected 2 written records, got %d", len(writer.WrittenRecords))
+	}
+}
+
+// MockBatchWriter records how many batch writes were made
+type MockBatchWriter struct {
+	MockAnalyticsWriter
+	Batches int
+}
+
+func (m *MockBatchWriter) WriteBatch(ctx context.Context, records []domain.AnalyticsRecord) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Batches++
+	m.WrittenRecords = append(m.WrittenRecords, records...)
+	return nil
+}
+
+func batchPayload(records ...domain.AnalyticsRecord) []byte {
+	payloadJSON, _ := json.Marshal(domain.WebhookPayload{
+		EventType: domain.EventTypeBatch,
+		Timestamp: 1700000000,
+		Records:   records,
+	})
+	return payloadJSON
+}
+
+func TestWebhookServiceProcessBatchOutcomes(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockBatchWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	valid := domain.AnalyticsRecord{RequestID: "req_1", Query: "q", Timestamp: 1700000000}
+	other := domain.AnalyticsRecord{RequestID: "req_2", Query: "q", Timestamp: 1700000000}
+	missingQuery := domain.AnalyticsRecord{RequestID: "req_3", Timestamp: 1700000000}
+
+	// Act
+	result, err := service.Process(context.Background(), batchPayload(valid, missingQuery, valid, other), signedHeaders("valid_signature"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []string{domain.RecordAccepted, domain.RecordInvalid, domain.RecordDuplicate, domain.RecordAccepted}
+	for i, status := range want {
+		if result.Records[i].Status != status {
+			t.Errorf("Record %d: expected %s, got %s", i, status, result.Records[i].Status)
+		}
+	}
+	if v := result.Records[1].Violations; len(v) != 1 || v[0].Pointer != "/query" || v[0].Rule != "minLength" {
+		t.Errorf("Expected a /query minLength violation, got %+v", result.Records[1])
+	}
+	if writer.Batches != 1 || len(writer.WrittenRecords) != 2 {
+		t.Errorf("Expected 2 records in 1 batch write, got %d in %d", len(writer.WrittenRecords), writer.Batches)
+	}
+}
+
+func TestWebhookServiceProcessBatchRetryWritesOnlyNewRecords(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	first := domain.AnalyticsRecord{RequestID: "req_1", Query: "q", Timestamp: 1700000000}
+	second := domain.AnalyticsRecord{RequestID: "req_2", Query: "q", Timestamp: 1700000000}
+
+	// Act: the retry is a new body, so it is a new delivery whose first record is already stored
+	service.Process(context.Background(), batchPayload(first), signedHeaders("valid_signature"))
+	result, err := service.Process(context.Background(), batchPayload(first, second), signedHeaders("valid_signature"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Count(domain.RecordDuplicate) != 1 || result.Count(domain.RecordAccepted) != 1 {
+		t.Errorf("Expected 1 duplicate and 1 accepted, got %+v", result.Records)
+	}
+	if len(writer.WrittenRecords) != 2 {
+		t.Errorf("Expected 2 written records in total, got %d", len(writer.WrittenRecords))
+	}
+}
+
+func TestWebhookServiceProcessBatchWriteFailureReleasesRecords(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	nonces := &MockNonceStore{}
+	writer := &MockBatchWriter{MockAnalyticsWriter: MockAnalyticsWriter{Error: domain.ErrDatabaseWrite}}
+	service := newTestService(validator, nonces, writer, &MockLogger{})
+
+	record := domain.AnalyticsRecord{RequestID: "req_1", Query: "q", Timestamp: 1700000000}
+
+	// Act
+	_, err := service.Process(context.Background(), batchPayload(record), signedHeaders("valid_signature"))
+
+	// Assert: both the delivery and its records may be retried
+	if !errors.Is(err, domain.ErrDatabaseWrite) {
+		t.Errorf("Expected database write error, got %v", err)
+	}
+	if len(nonces.Seen) != 0 || len(nonces.Released) != 2 {
+		t.Errorf("Expected message and record IDs released, got seen=%v released=%v", nonces.Seen, nonces.Released)
+	}
+}
+
+func TestWebhookServiceProcessBatchTooLarge(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	service := newTestService(validator, &MockNonceStore{}, &MockAnalyticsWriter{}, &MockLogger{})
+
+	records := make([]domain.AnalyticsRecord, domain.MaxBatchRecords+1)
+
+	// Act
+	_, err := service.Process(context.Background(), batchPayload(records...), signedHeaders("valid_signature"))
+
+	// Assert
+	if !errors.Is(err, domain.ErrBatchTooLarge) {
+		t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestWebhookServiceProcessCompressedBody(t *testing.T) {
+	// Arrange: the sender signs the gzip bytes exactly as they go on the wire
+	validator := domain.NewHMACValidator([]domain.SigningKey{{ID: "default", Secret: "test-secret"}}, time.Minute, false, &MockLogger{})
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"eventType":"analytics_record_created","data":{"requestId":"req_123","query":"q","timestamp":1700000000}}`))
+	gz.Close()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(compressed.Bytes())
+
+	headers := http.Header{}
+	headers.Set(domain.TimestampHeader, timestamp)
+	headers.Set(domain.SignatureHeader, domain.TimestampedSignaturePrefix+hex.EncodeToString(mac.Sum(nil)))
+	headers.Set(domain.ContentEncodingHeader, "gzip")
+
+	// Act
+	_, err := service.Process(context.Background(), compressed.Bytes(), headers)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(writer.WrittenRecords) != 1 || writer.WrittenRecords[0].RequestID != "req_123" {
+		t.Errorf("Expected decompressed record to be written, got %+v", writer.WrittenRecords)
+	}
+}
+
+func TestWebhookServiceProcessDecodesOnlyAfterSignature(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{Error: domain.ErrInvalidSignature}
+	service := newTestService(validator, &MockNonceStore{}, &MockAnalyticsWriter{}, &MockLogger{})
+
+	headers := signedHeaders("invalid_signature")
+	headers.Set(domain.ContentEncodingHeader, "gzip")
+
+	// Act
+	_, err := service.Process(context.Background(), []byte("not gzip"), headers)
+
+	// Assert: an unauthenticated body is never decompressed
+	if !errors.Is(err, domain.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestWebhookServiceProcessCloudEventBinaryMode(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	nonces := &MockNonceStore{}
+	writer := &MockAnalyticsWriter{}
+	service := newTestService(validator, nonces, writer, &MockLogger{})
+
+	headers := signedHeaders("valid_signature")
+	headers.Set(domain.CloudEventsSpecVersionHeader, "1.0")
+	headers.Set(domain.CloudEventsIDHeader, "evt-1")
+	headers.Set(domain.CloudEventsSourceHeader, "//lambda/cv-chatbot")
+	headers.Set(domain.CloudEventsTypeHeader, domain.EventTypeCreated)
+
+	body := []byte(`{"requestId":"req_123","query":"q","timestamp":1700000000}`)
+	replay := headers.Clone()
+	replay.Set(domain.CloudEventsIDHeader, "evt-2")
+
+	// Act
+	_, err := service.Process(context.Background(), body, headers)
+	_, replayErr := service.Process(context.Background(), body, replay)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(writer.WrittenRecords) != 1 || writer.WrittenRecords[0].CloudEvent == nil || writer.WrittenRecords[0].CloudEvent.ID != "evt-1" {
+		t.Errorf("Expected record written with its cloudevent attributes, got %+v", writer.WrittenRecords)
+	}
+	if !errors.Is(replayErr, domain.ErrDuplicateDelivery) {
+		t.Errorf("Expected a replay with a new unsigned ce-id to be a duplicate, got %v", replayErr)
+	}
+}
+
+func TestWebhookServiceProcessRedactsBeforeStoring(t *testing.T) {
+	// Arrange
+	validator := &MockSignatureValidator{ShouldValidate: true}
+	writer := &MockBatchWriter{}
+	service := newTestService(validator, &MockNonceStore{}, writer, &MockLogger{})
+
+	withEmail := domain.AnalyticsRecord{RequestID: "req_1", Query: "reach me on jane@example.com", Timestamp: 1700000000}
+	clean := domain.AnalyticsRecord{RequestID: "req_2", Query: "Go experience?", Timestamp: 1700000000}
+
+	// Act
+	_, err := service.Process(context.Background(), batchPayload(withEmail, clean), signedHeaders("valid_signature"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	stored := writer.WrittenRecords
+	if len(stored) != 2 || stored[0].Query != "reach me on [redacted:email]" || stored[1].Query != clean.Query {
+		t.Fatalf("Expected only the email to be masked, got %+v", stored)
+	}
+	if len(stored[0].Redactions) != 1 || stored[0].Redactions[0] != domain.RedactionEmail || stored[1].Redactions != nil {
+		t.Errorf("Expected the email rule on the first record only, got %v and %v", stored[0].Redactions, stored[1].Redactions)
+	}
+}